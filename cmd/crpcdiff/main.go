@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	cmd "github.com/cuvva/cuvva-public-go/tools/crpcdiff/commands"
+)
+
+func main() {
+	cmd.DiffCmd.Flags().Bool("json", false, "print the diff as JSON")
+	cmd.DiffCmd.Flags().Bool("allow-breaking", false, "do not fail on backwards-incompatible changes")
+
+	if err := cmd.DiffCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	cmd "github.com/cuvva/cuvva-public-go/tools/crpcgen/commands"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	rootCmd.AddCommand(cmd.GenerateCmd)
+
+	cmd.GenerateCmd.Flags().StringP("interface", "i", "Service", "Name of the service interface to generate from")
+	cmd.GenerateCmd.Flags().StringP("version", "v", "", "Version to pin methods without a crpc:version directive to")
+	cmd.GenerateCmd.Flags().StringP("package", "p", "", "Package name of the generated file")
+	cmd.GenerateCmd.Flags().StringP("output", "o", "", "File to write to, defaults to stdout")
+	cmd.GenerateCmd.MarkFlagRequired("package")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "crpcgen",
+	Short: "Tool to generate typed crpc clients",
+	Long:  "A CLI tool to generate typed crpc clients and fakes from a service interface",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
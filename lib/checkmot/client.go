@@ -14,8 +14,9 @@ type Client struct {
 	*jsonclient.Client
 }
 
-// NewClient creates a new vehicle service client.
-func NewClient(baseURL, key string) *Client {
+// NewClient creates a new vehicle service client, configured by any options,
+// such as jsonclient.WithInstrumentation.
+func NewClient(baseURL, key string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Transport: &roundTripper{
 			apiKey: key,
@@ -37,7 +38,7 @@ func NewClient(baseURL, key string) *Client {
 	}
 
 	return &Client{
-		jsonclient.NewClient(baseURL, httpClient),
+		jsonclient.NewClient(baseURL, httpClient, opts...),
 	}
 }
 
@@ -18,9 +18,12 @@ const (
 	NotFound          = "not_found"
 	RouteNotFound     = "route_not_found"
 	MethodNotAllowed  = "method_not_allowed"
+	Conflict          = "conflict"
 	Unknown           = "unknown"
 	NoLongerSupported = "no_longer_supported"
 	TooManyRequests   = "too_many_requests"
+	Unavailable       = "unavailable"
+	CircuitOpen       = "circuit_open"
 	ContextCanceled   = "context_canceled"
 	EOF               = "eof"
 	UnexpectedEOF     = "unexpected_eof"
@@ -78,12 +81,18 @@ func (e E) StatusCode() int {
 	case MethodNotAllowed:
 		return http.StatusMethodNotAllowed
 
+	case Conflict:
+		return http.StatusConflict
+
 	case NoLongerSupported:
 		return http.StatusGone
 
 	case TooManyRequests:
 		return http.StatusTooManyRequests
 
+	case Unavailable, CircuitOpen:
+		return http.StatusServiceUnavailable
+
 	case Unknown, CoercionError, RequestTimeout:
 		return http.StatusInternalServerError
 	}
@@ -46,6 +46,9 @@ func TestE(t *testing.T) {
 			{"Unauthorized", E{Code: Unauthorized}, http.StatusUnauthorized},
 			{"AccessDenied", E{Code: AccessDenied}, http.StatusForbidden},
 			{"NotFound", E{Code: NotFound}, http.StatusNotFound},
+			{"Conflict", E{Code: Conflict}, http.StatusConflict},
+			{"Unavailable", E{Code: Unavailable}, http.StatusServiceUnavailable},
+			{"CircuitOpen", E{Code: CircuitOpen}, http.StatusServiceUnavailable},
 			{"Unknown", E{Code: Unknown}, http.StatusInternalServerError},
 			{"Handled", E{Code: "some_developer_code"}, http.StatusBadRequest},
 		}
@@ -0,0 +1,80 @@
+package cher
+
+import (
+	"errors"
+	"sync"
+)
+
+// Translator converts an error into an E, returning false if it does not
+// recognise the error. Translators should use errors.As to find the error
+// they translate, so that wrapped errors are also translated.
+type Translator func(err error) (E, bool)
+
+var translators struct {
+	sync.RWMutex
+
+	fns []*Translator
+}
+
+// RegisterTranslator adds a Translator consulted by Translate, typically
+// from an init function. Translators are consulted in the order they are
+// registered. The returned function removes the translator again, such as
+// in the cleanup of a test.
+func RegisterTranslator(fn Translator) (unregister func()) {
+	translators.Lock()
+	defer translators.Unlock()
+
+	// translators are held by pointer, as funcs cannot be compared
+	registered := &fn
+	translators.fns = append(translators.fns, registered)
+
+	return func() {
+		translators.Lock()
+		defer translators.Unlock()
+
+		for i, fn := range translators.fns {
+			if fn == registered {
+				translators.fns = append(translators.fns[:i:i], translators.fns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// TranslatorFor returns a Translator converting errors of type T, found
+// anywhere in the chain of wrapped errors, using fn.
+func TranslatorFor[T error](fn func(T) E) Translator {
+	return func(err error) (E, bool) {
+		var target T
+		if errors.As(err, &target) {
+			return fn(target), true
+		}
+
+		return E{}, false
+	}
+}
+
+// Translate resolves an error into an E. An E anywhere in the chain of
+// wrapped errors is returned as-is, otherwise the registered translators are
+// consulted in turn. If the error cannot be resolved, false is returned.
+func Translate(err error) (E, bool) {
+	if err == nil {
+		return E{}, false
+	}
+
+	var e E
+	if errors.As(err, &e) {
+		return e, true
+	}
+
+	translators.RLock()
+	defer translators.RUnlock()
+
+	for _, fn := range translators.fns {
+		if e, ok := (*fn)(err); ok {
+			return e, true
+		}
+	}
+
+	return E{}, false
+}
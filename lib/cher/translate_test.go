@@ -0,0 +1,73 @@
+package cher
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testDomainError struct {
+	ID string
+}
+
+func (e *testDomainError) Error() string {
+	return "domain error " + e.ID
+}
+
+func TestTranslate(t *testing.T) {
+	t.Cleanup(RegisterTranslator(TranslatorFor(func(err *testDomainError) E {
+		return New(NotFound, M{"id": err.ID})
+	})))
+
+	tests := []struct {
+		Name  string
+		Error error
+		E     E
+		OK    bool
+	}{
+		{"Nil", nil, E{}, false},
+		{"Unknown", errors.New("foo"), E{}, false},
+		{"Cher", New(NotFound, nil), New(NotFound, nil), true},
+		{"WrappedCher", fmt.Errorf("foo: %w", New(Conflict, nil)), New(Conflict, nil), true},
+		{"PkgWrappedCher", pkgerrors.Wrap(New(Conflict, nil), "foo"), New(Conflict, nil), true},
+		{"Translated", &testDomainError{ID: "1"}, New(NotFound, M{"id": "1"}), true},
+		{"WrappedTranslated", fmt.Errorf("foo: %w", &testDomainError{ID: "2"}), New(NotFound, M{"id": "2"}), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e, ok := Translate(test.Error)
+
+			assert.Equal(t, test.OK, ok)
+			assert.Equal(t, test.E, e)
+		})
+	}
+}
+
+func TestUnregisterTranslator(t *testing.T) {
+	unregisterFirst := RegisterTranslator(TranslatorFor(func(err *testDomainError) E {
+		return New(NotFound, nil)
+	}))
+	unregisterSecond := RegisterTranslator(TranslatorFor(func(err *testDomainError) E {
+		return New(Conflict, nil)
+	}))
+
+	e, ok := Translate(&testDomainError{})
+	assert.True(t, ok)
+	assert.Equal(t, NotFound, e.Code)
+
+	unregisterFirst()
+
+	e, ok = Translate(&testDomainError{})
+	assert.True(t, ok)
+	assert.Equal(t, Conflict, e.Code)
+
+	unregisterSecond()
+	unregisterSecond()
+
+	_, ok = Translate(&testDomainError{})
+	assert.False(t, ok)
+}
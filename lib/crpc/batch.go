@@ -0,0 +1,241 @@
+package crpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/clog"
+	"github.com/sirupsen/logrus"
+)
+
+// BatchPath is the path the batch route is served on when Batch is configured.
+const BatchPath = "/_batch"
+
+const (
+	// DefaultBatchMaxCalls is the default maximum number of calls accepted in
+	// a single batch.
+	DefaultBatchMaxCalls = 20
+
+	// DefaultBatchMaxBodySize is the default largest batch request body, in
+	// bytes.
+	DefaultBatchMaxBodySize = 1 << 20
+)
+
+// BatchConfig configures the batch route of a Server.
+type BatchConfig struct {
+	// MaxCalls is the maximum number of calls accepted in a single batch.
+	// Defaults to DefaultBatchMaxCalls if zero.
+	MaxCalls int
+
+	// MaxBodySize is the largest batch request body, in bytes. Defaults to
+	// DefaultBatchMaxBodySize if zero.
+	MaxBodySize int64
+
+	// Concurrency is the maximum number of calls in a batch which are
+	// executed at once. Calls are executed in serial if less than 1.
+	Concurrency int
+}
+
+// BatchCall is a single call within a batch request.
+type BatchCall struct {
+	Version string          `json:"version"`
+	Method  string          `json:"method"`
+	Body    json.RawMessage `json:"body,omitempty"`
+
+	// IdempotencyKey is sent as the Idempotency-Key header of the call. The
+	// header of the batch request itself is not passed on to its calls, as
+	// calls would otherwise share a key.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// BatchResult is the outcome of a single call within a batch request,
+// returned in the same position as the call in the request. Either Body or
+// Error will be set, unless the call returned no content.
+type BatchResult struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Error  *cher.E         `json:"error,omitempty"`
+}
+
+func (c *BatchConfig) maxCalls() int {
+	if c.MaxCalls > 0 {
+		return c.MaxCalls
+	}
+
+	return DefaultBatchMaxCalls
+}
+
+func (c *BatchConfig) maxBodySize() int64 {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+
+	return DefaultBatchMaxBodySize
+}
+
+// serveBatch decodes a batch of calls and executes each of them through
+// Serve, so each call is subject to the same authentication, validation and
+// middleware as if it had been made individually.
+func (s *Server) serveBatch(w http.ResponseWriter, req *Request) error {
+	var calls []BatchCall
+
+	body := http.MaxBytesReader(w, req.Body, s.Batch.maxBodySize())
+
+	var tooLarge *http.MaxBytesError
+
+	err := json.NewDecoder(body).Decode(&calls)
+	if err == io.EOF {
+		return cher.New(cher.BadRequest, nil, cher.New("missing_request_body", nil))
+	} else if errors.As(err, &tooLarge) {
+		return cher.New("batch_too_large", cher.M{"max_body_size": tooLarge.Limit})
+	} else if err != nil {
+		return err
+	}
+
+	if len(calls) == 0 {
+		return cher.New(cher.BadRequest, nil, cher.New("empty_batch", nil))
+	} else if maxCalls := s.Batch.maxCalls(); len(calls) > maxCalls {
+		return cher.New("batch_too_large", cher.M{"calls": len(calls), "max_calls": maxCalls})
+	}
+
+	concurrency := s.Batch.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]BatchResult, len(calls))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, call := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, call BatchCall) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results[i] = s.serveBatchCall(req, call)
+		}(i, call)
+	}
+
+	wg.Wait()
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(results)
+}
+
+func (s *Server) serveBatchCall(parent *Request, call BatchCall) (result BatchResult) {
+	// calls run on their own goroutine, outside the recovery of net/http, so
+	// a panic must fail the call rather than the process
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			st := make([]byte, 1<<16)
+			st = st[:runtime.Stack(st, false)]
+
+			clog.Get(parent.Context()).WithFields(logrus.Fields{
+				"error":       "panic",
+				"panic":       fmt.Sprint(recovered),
+				"stack_trace": string(st),
+				"rpc_method":  call.Method,
+				"rpc_version": call.Version,
+			}).Error("rpc batch call panicked")
+
+			body := cher.New(cher.Unknown, nil)
+
+			result = BatchResult{
+				Status: body.StatusCode(),
+				Error:  &body,
+			}
+		}
+	}()
+
+	if s.streams(call.Version, call.Method) {
+		body := cher.New(cher.BadRequest, nil, cher.New("streaming_not_batchable", cher.M{"method": call.Method, "version": call.Version}))
+
+		return BatchResult{
+			Status: body.StatusCode(),
+			Error:  &body,
+		}
+	}
+
+	var body io.ReadCloser = http.NoBody
+	if len(call.Body) > 0 && !bytes.Equal(call.Body, []byte("null")) {
+		body = io.NopCloser(bytes.NewReader(call.Body))
+	}
+
+	req := &Request{
+		Version: call.Version,
+		Method:  call.Method,
+
+		Body: body,
+
+		RemoteAddr:    parent.RemoteAddr,
+		BrowserOrigin: parent.BrowserOrigin,
+		Header:        parent.Header.Clone(),
+	}
+	req.ctx = setRequestContext(parent.Context(), req)
+
+	// calls are always encoded as JSON within the batch
+	req.Header.Del("Content-Type")
+	req.Header.Del("Accept")
+
+	req.Header.Del(IdempotencyKeyHeader)
+	if call.IdempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, call.IdempotencyKey)
+	}
+
+	res := newResponseBuffer(nil)
+
+	if err := s.Serve(res, req); err != nil {
+		body := coerceError(err)
+
+		return BatchResult{
+			Status: body.StatusCode(),
+			Header: res.Header(),
+			Error:  &body,
+		}
+	}
+
+	result = BatchResult{
+		Status: res.Status(),
+		Header: res.Header(),
+	}
+
+	b := bytes.TrimSpace(res.body.Bytes())
+
+	switch {
+	case len(b) == 0:
+		// no content
+
+	case json.Valid(b):
+		result.Body = b
+
+	default:
+		// HandlerFuncs may respond with something other than JSON
+		result.Body, _ = json.Marshal(string(b))
+	}
+
+	return result
+}
+
+// streams reports whether the method resolved for version streams its
+// response, which cannot be returned within a batch.
+func (s *Server) streams(version, method string) bool {
+	hn, ok := s.resolvedMethods[version][method]
+
+	return ok && hn != nil && hn.streams
+}
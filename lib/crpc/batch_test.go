@@ -0,0 +1,205 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchServer(auth MiddlewareFunc) *Server {
+	zs := NewServer(auth)
+	zs.Batch = &BatchConfig{MaxCalls: 3, Concurrency: 2}
+
+	zs.Register("greet", "2019-01-01", catalogueSchema, func(_ context.Context, req *struct {
+		Name string `json:"name"`
+	}) (*testResponse, error) {
+		return &testResponse{Message: "hello " + req.Name}, nil
+	})
+	zs.Register("ping", "2019-01-01", nil, func(context.Context) error { return nil })
+
+	return zs
+}
+
+func serveBatch(zs *Server, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/_batch", strings.NewReader(body))
+
+	zs.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestBatch(t *testing.T) {
+	zs := newBatchServer(UnsafeNoAuthentication)
+
+	w := serveBatch(zs, `[
+		{"version": "2019-01-01", "method": "greet", "body": {"name": "james"}},
+		{"version": "2019-01-01", "method": "greet", "body": {}},
+		{"version": "latest", "method": "ping"}
+	]`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var results []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+
+	assert.Equal(t, http.StatusOK, results[0].Status)
+	assert.JSONEq(t, `{"message": "hello james"}`, string(results[0].Body))
+	assert.Nil(t, results[0].Error)
+
+	assert.Equal(t, http.StatusBadRequest, results[1].Status)
+	require.NotNil(t, results[1].Error)
+	assert.Equal(t, cher.BadRequest, results[1].Error.Code)
+
+	assert.Equal(t, http.StatusNoContent, results[2].Status)
+	assert.Nil(t, results[2].Body)
+	assert.Contains(t, results[2].Header.Get(CuvvaEndpointStatus), "latest")
+}
+
+func TestBatchAuthenticatesEachCall(t *testing.T) {
+	var calls int32
+
+	zs := newBatchServer(func(next HandlerFunc) HandlerFunc {
+		return func(res http.ResponseWriter, req *Request) error {
+			atomic.AddInt32(&calls, 1)
+
+			if req.GetHeader("Authorization") == "" {
+				return cher.New(cher.Unauthorized, nil)
+			}
+
+			return next(res, req)
+		}
+	})
+
+	w := serveBatch(zs, `[{"version": "2019-01-01", "method": "ping"}, {"version": "2019-01-01", "method": "ping"}]`)
+
+	var results []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, int32(2), calls)
+	assert.Equal(t, http.StatusUnauthorized, results[0].Status)
+	assert.Equal(t, http.StatusUnauthorized, results[1].Status)
+}
+
+func TestBatchErrors(t *testing.T) {
+	tests := []struct {
+		Name string
+		Body string
+		Code string
+	}{
+		{"Empty", `[]`, cher.BadRequest},
+		{"Missing", ``, cher.BadRequest},
+		{"InvalidJSON", `[{`, cher.Unknown},
+		{"TooLarge", `[{}, {}, {}, {}]`, "batch_too_large"},
+		{"BodyTooLarge", `[{"version": "` + strings.Repeat("x", 2048) + `"}]`, "batch_too_large"},
+	}
+
+	zs := newBatchServer(UnsafeNoAuthentication)
+	zs.Batch.MaxBodySize = 1024
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := serveBatch(zs, test.Body)
+
+			var body cher.E
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, test.Code, body.Code)
+		})
+	}
+}
+
+func TestBatchDisabled(t *testing.T) {
+	zs := newBatchServer(UnsafeNoAuthentication)
+	zs.Batch = nil
+
+	w := serveBatch(zs, `[{"version": "2019-01-01", "method": "ping"}]`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBatchDefaults(t *testing.T) {
+	zs := newBatchServer(UnsafeNoAuthentication)
+	zs.Batch = &BatchConfig{}
+
+	calls := strings.Repeat(`{"version": "2019-01-01", "method": "ping"},`, DefaultBatchMaxCalls+1)
+
+	w := serveBatch(zs, "["+strings.TrimSuffix(calls, ",")+"]")
+
+	var body cher.E
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "batch_too_large", body.Code)
+	assert.EqualValues(t, DefaultBatchMaxCalls, body.Meta["max_calls"])
+}
+
+func TestBatchRecoversPanics(t *testing.T) {
+	zs := newBatchServer(UnsafeNoAuthentication)
+	zs.Register("boom", "2019-01-01", nil, func(context.Context) error { panic("x") })
+
+	w := serveBatch(zs, `[{"version": "2019-01-01", "method": "boom"}, {"version": "2019-01-01", "method": "ping"}]`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var results []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 2)
+
+	assert.Equal(t, http.StatusInternalServerError, results[0].Status)
+	require.NotNil(t, results[0].Error)
+	assert.Equal(t, cher.Unknown, results[0].Error.Code)
+
+	assert.Equal(t, http.StatusNoContent, results[1].Status)
+}
+
+func TestBatchIdempotencyKeys(t *testing.T) {
+	var keys []string
+
+	zs := newBatchServer(UnsafeNoAuthentication)
+	zs.Batch.Concurrency = 1
+	zs.Register("pay", "2019-01-01", nil, func(context.Context) error { return nil }, func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			keys = append(keys, r.GetHeader(IdempotencyKeyHeader))
+
+			return next(w, r)
+		}
+	})
+
+	r, _ := http.NewRequest("POST", "/_batch", strings.NewReader(`[
+		{"version": "2019-01-01", "method": "pay", "idempotency_key": "key_1"},
+		{"version": "2019-01-01", "method": "pay"}
+	]`))
+	r.Header.Set(IdempotencyKeyHeader, "batch_key")
+
+	w := httptest.NewRecorder()
+	zs.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"key_1", ""}, keys)
+}
+
+func TestBatchRejectsStreams(t *testing.T) {
+	zs := newBatchServer(UnsafeNoAuthentication)
+	zs.Register("stream", "2019-01-01", nil, streamIterHandler(2, nil))
+
+	w := serveBatch(zs, `[{"version": "2019-01-01", "method": "stream"}]`)
+
+	var results []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+
+	assert.Equal(t, http.StatusBadRequest, results[0].Status)
+	assert.Nil(t, results[0].Body)
+	require.NotNil(t, results[0].Error)
+	require.Len(t, results[0].Error.Reasons, 1)
+	assert.Equal(t, "streaming_not_batchable", results[0].Error.Reasons[0].Code)
+}
@@ -0,0 +1,288 @@
+package crpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/cuvva/cuvva-public-go/lib/servicecontext"
+	"github.com/cuvva/cuvva-public-go/lib/version"
+)
+
+const (
+	// CataloguePath is the path the method catalogue is served on when
+	// ExposeCatalogue is enabled.
+	CataloguePath = "/_catalogue"
+
+	// OpenAPIPath is the path the OpenAPI document is served on when
+	// ExposeCatalogue is enabled.
+	OpenAPIPath = "/_catalogue/openapi.json"
+)
+
+// MethodInfo describes a single method as it is resolved for a version.
+type MethodInfo struct {
+	Method string `json:"method"`
+
+	// Version is the version the method was registered against, which may
+	// be earlier than the version it has been resolved for.
+	Version string `json:"version"`
+
+	AcceptsInput  bool `json:"accepts_input"`
+	ReturnsResult bool `json:"returns_result"`
+
+	// Streams is true if the result is streamed as newline delimited JSON.
+	Streams bool `json:"streams,omitempty"`
+
+	// Schema is the JSON Schema used to validate the request body, if the
+	// method accepts input.
+	Schema interface{} `json:"schema,omitempty"`
+
+	// Permissions are the scopes and roles required to call the method, if
+	// any are required.
+	Permissions *Permissions `json:"permissions,omitempty"`
+
+	// Deprecation describes the withdrawal of the method, if it has been
+	// deprecated.
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+
+	// BrowserAccessDenied is true if requests from browsers are rejected.
+	BrowserAccessDenied bool `json:"browser_access_denied,omitempty"`
+}
+
+// Catalogue describes every method exposed by a Server.
+type Catalogue struct {
+	// Latest is the version resolved when a client requests VersionLatest.
+	Latest string `json:"latest,omitempty"`
+
+	// Versions maps each callable version to its methods, sorted by name.
+	// VersionLatest is omitted as it is an alias of Latest.
+	Versions map[string][]MethodInfo `json:"versions"`
+}
+
+// Catalogue returns a description of every method and version registered
+// with the server, including methods inherited from earlier versions.
+func (s *Server) Catalogue() *Catalogue {
+	c := &Catalogue{
+		Versions: make(map[string][]MethodInfo),
+	}
+
+	for _, version := range s.knownVersions() {
+		methodSet := s.resolvedMethods[version]
+
+		methods := make([]MethodInfo, 0, len(methodSet))
+		for _, hn := range methodSet {
+			methods = append(methods, hn.info())
+		}
+
+		sort.Slice(methods, func(i, j int) bool {
+			return methods[i].Method < methods[j].Method
+		})
+
+		c.Versions[version] = methods
+
+		if version != VersionPreview {
+			c.Latest = version
+		}
+	}
+
+	return c
+}
+
+// knownVersions returns every resolved version except VersionLatest, with
+// dated versions sorted earliest first followed by VersionPreview.
+func (s *Server) knownVersions() []string {
+	versions := make([]string, 0, len(s.resolvedMethods))
+
+	for version := range s.resolvedMethods {
+		if version != VersionLatest {
+			versions = append(versions, version)
+		}
+	}
+
+	// "preview" sorts after any "20xx-xx-xx" version
+	sort.Strings(versions)
+
+	return versions
+}
+
+func (hn *handler) info() MethodInfo {
+	mi := MethodInfo{
+		Method:        hn.method,
+		Version:       hn.v,
+		AcceptsInput:  hn.schema != nil,
+		ReturnsResult: hn.returnsResult,
+		Streams:       hn.streams,
+		Permissions:   hn.permissions,
+		Deprecation:   hn.deprecation,
+
+		BrowserAccessDenied: hn.browserDenied,
+	}
+
+	if hn.schema != nil {
+		// schemas are compiled at registration, so will always load
+		mi.Schema, _ = hn.schema.LoadJSON()
+	}
+
+	return mi
+}
+
+// OpenAPIInfo is the metadata describing the API in an OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIDocument is the subset of an OpenAPI 3.1 document required to
+// describe a crpc Server.
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components"`
+}
+
+// OpenAPIPathItem describes the operations available on a single path.
+type OpenAPIPathItem struct {
+	Post *OpenAPIOperation `json:"post,omitempty"`
+}
+
+// OpenAPIOperation describes a single API operation on a path.
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Tags        []string                   `json:"tags,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+}
+
+// OpenAPIRequestBody describes the request body of an operation.
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response of an operation.
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the schema of a request or response body.
+type OpenAPIMediaType struct {
+	Schema interface{} `json:"schema,omitempty"`
+}
+
+// OpenAPIComponents holds the reusable schemas of an OpenAPI document.
+type OpenAPIComponents struct {
+	Schemas map[string]interface{} `json:"schemas"`
+}
+
+const jsonContentType = "application/json"
+
+// errorSchema is the JSON Schema of cher.E, returned by every method.
+var errorSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"code"},
+	"properties": map[string]interface{}{
+		"code":    map[string]interface{}{"type": "string"},
+		"meta":    map[string]interface{}{"type": "object"},
+		"reasons": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+	},
+}
+
+// OpenAPI returns an OpenAPI 3.1 document with a path for each method on each
+// version (excluding VersionLatest) exposed by the server.
+func (s *Server) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   make(map[string]OpenAPIPathItem),
+		Components: OpenAPIComponents{
+			Schemas: map[string]interface{}{
+				"Error": errorSchema,
+			},
+		},
+	}
+
+	for version, methods := range s.Catalogue().Versions {
+		for _, mi := range methods {
+			doc.Paths[fmt.Sprintf("/%s/%s", version, mi.Method)] = OpenAPIPathItem{
+				Post: mi.operation(version),
+			}
+		}
+	}
+
+	return doc
+}
+
+func (mi MethodInfo) operation(version string) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		OperationID: fmt.Sprintf("%s_%s", mi.Method, version),
+		Tags:        []string{version},
+		Deprecated:  mi.Deprecation != nil,
+		Responses: map[string]OpenAPIResponse{
+			"default": {
+				Description: "Error",
+				Content: map[string]OpenAPIMediaType{
+					jsonContentType: {Schema: map[string]string{"$ref": "#/components/schemas/Error"}},
+				},
+			},
+		},
+	}
+
+	if mi.AcceptsInput {
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content: map[string]OpenAPIMediaType{
+				jsonContentType: {Schema: mi.Schema},
+			},
+		}
+	}
+
+	if mi.ReturnsResult {
+		contentType := jsonContentType
+		if mi.Streams {
+			contentType = StreamContentType
+		}
+
+		op.Responses["200"] = OpenAPIResponse{
+			Description: "OK",
+			Content: map[string]OpenAPIMediaType{
+				contentType: {},
+			},
+		}
+	} else {
+		op.Responses["204"] = OpenAPIResponse{
+			Description: "No Content",
+		}
+	}
+
+	return op
+}
+
+func (s *Server) serveCatalogue(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.Catalogue())
+}
+
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	info := OpenAPIInfo{
+		Title:   "crpc",
+		Version: version.Truncated,
+	}
+
+	if svc := servicecontext.GetContext(r.Context()); svc != nil {
+		info.Title = svc.Name
+	}
+
+	writeJSON(w, s.OpenAPI(info))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
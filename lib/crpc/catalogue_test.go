@@ -0,0 +1,111 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var catalogueSchema = gojsonschema.NewStringLoader(`{
+	"type": "object",
+	"required": [ "name" ],
+	"properties": {
+		"name": { "type": "string" }
+	}
+}`)
+
+func newCatalogueServer() *Server {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	zs.Register("ping", "2019-01-01", nil, func(context.Context) error { return nil })
+	zs.Register("greet", "2019-01-01", catalogueSchema, func(context.Context, *struct{}) (*testResponse, error) { return nil, nil })
+	zs.Register("ping", "2019-02-02", nil, nil)
+	zs.Register("wave", "preview", nil, makeRPCCall("wave"))
+
+	return zs
+}
+
+func TestCatalogue(t *testing.T) {
+	c := newCatalogueServer().Catalogue()
+
+	assert.Equal(t, "2019-02-02", c.Latest)
+	assert.Len(t, c.Versions, 3)
+
+	v1 := c.Versions["2019-01-01"]
+	require.Len(t, v1, 2)
+	assert.Equal(t, "greet", v1[0].Method)
+	assert.True(t, v1[0].AcceptsInput)
+	assert.True(t, v1[0].ReturnsResult)
+	assert.NotNil(t, v1[0].Schema)
+	assert.Equal(t, "ping", v1[1].Method)
+	assert.False(t, v1[1].AcceptsInput)
+	assert.False(t, v1[1].ReturnsResult)
+
+	v2 := c.Versions["2019-02-02"]
+	require.Len(t, v2, 1)
+	assert.Equal(t, "greet", v2[0].Method)
+	assert.Equal(t, "2019-01-01", v2[0].Version)
+
+	preview := c.Versions["preview"]
+	require.Len(t, preview, 1)
+	assert.Equal(t, "wave", preview[0].Method)
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := newCatalogueServer().OpenAPI(OpenAPIInfo{Title: "test", Version: "1"})
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Len(t, doc.Paths, 4)
+
+	greet := doc.Paths["/2019-02-02/greet"].Post
+	require.NotNil(t, greet)
+	require.NotNil(t, greet.RequestBody)
+	assert.NotNil(t, greet.RequestBody.Content["application/json"].Schema)
+	assert.Contains(t, greet.Responses, "200")
+
+	ping := doc.Paths["/2019-01-01/ping"].Post
+	require.NotNil(t, ping)
+	assert.Nil(t, ping.RequestBody)
+	assert.Contains(t, ping.Responses, "204")
+
+	assert.NotContains(t, doc.Paths, "/2019-02-02/ping")
+	assert.NotContains(t, doc.Paths, "/latest/greet")
+}
+
+func TestServeCatalogue(t *testing.T) {
+	zs := newCatalogueServer()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/_catalogue", nil)
+	zs.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	zs.ExposeCatalogue = true
+
+	w = httptest.NewRecorder()
+	zs.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var c Catalogue
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &c))
+	assert.Equal(t, "2019-02-02", c.Latest)
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/_catalogue/openapi.json", nil)
+	zs.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc OpenAPIDocument
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Contains(t, doc.Paths, "/preview/wave")
+}
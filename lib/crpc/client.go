@@ -1,11 +1,16 @@
 package crpc
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
 
+	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/cuvva/cuvva-public-go/lib/jsonclient"
 	"github.com/cuvva/cuvva-public-go/lib/servicecontext"
 	"github.com/cuvva/cuvva-public-go/lib/version"
@@ -18,12 +23,18 @@ const userAgentTemplateWithService = "crpc/%s (+https://cuvva.com) [%s/%s]"
 // variables/structs and the authenticated round tripper live there.
 type Client struct {
 	*jsonclient.Client
+
+	// Codec encodes request bodies and is preferred for response bodies, such
+	// as MsgPackCodec. Requests are encoded as JSON when nil. Streams and
+	// errors are always encoded as JSON.
+	Codec Codec
 }
 
 // NewClient returns a client configured with a transport scheme, remote host
-// and URL prefix supplied as a URL <scheme>://<host></prefix>
-func NewClient(ctx context.Context, baseURL string, c *http.Client) *Client {
-	jcc := jsonclient.NewClient(baseURL, c)
+// and URL prefix supplied as a URL <scheme>://<host></prefix>, and then by
+// any options, such as jsonclient.WithInstrumentation.
+func NewClient(ctx context.Context, baseURL string, c *http.Client, opts ...jsonclient.Option) *Client {
+	jcc := jsonclient.NewClient(baseURL, c, opts...)
 
 	svc := servicecontext.GetContext(ctx)
 	if svc != nil {
@@ -32,7 +43,7 @@ func NewClient(ctx context.Context, baseURL string, c *http.Client) *Client {
 		jcc.UserAgent = fmt.Sprintf(userAgentTemplate, version.Truncated)
 	}
 
-	return &Client{jcc}
+	return &Client{Client: jcc}
 }
 
 // WithUASuffix updates the current user agent with an additional string
@@ -41,10 +52,95 @@ func (c *Client) WithUASuffix(suffix string) *Client {
 	return c
 }
 
-// Do executes an RPC request against the configured server.
+// Do executes an RPC request against the configured server. The time
+// remaining before the deadline of ctx, if it has one, is sent in the
+// Request-Timeout header so the server stops work the caller has given up on.
 func (c *Client) Do(ctx context.Context, method, version string, src, dst interface{}, requestModifiers ...func(r *http.Request)) error {
+	requestModifiers = append([]func(r *http.Request){withRequestTimeout(ctx)}, requestModifiers...)
+
+	if c.Codec != nil && c.Codec != JSONCodec {
+		return wrapClientError(method, version, c.doCodec(ctx, method, version, src, dst, requestModifiers))
+	}
+
 	err := c.Client.Do(ctx, "POST", path.Join(version, method), nil, src, dst, requestModifiers...)
 
+	return wrapClientError(method, version, err)
+}
+
+// doCodec executes an RPC request with the request body encoded by the
+// client's codec, decoding the response with the codec of its Content-Type.
+func (c *Client) doCodec(ctx context.Context, method, version string, src, dst interface{}, requestModifiers []func(r *http.Request)) error {
+	headers := http.Header{
+		"Accept": []string{c.Codec.ContentType() + ", " + JSONContentType + ";q=0.5"},
+	}
+
+	if src != nil {
+		var buf bytes.Buffer
+		if err := c.Codec.Encode(&buf, src); err != nil {
+			return &ClientTransportError{method, version, "could not marshal", err}
+		}
+
+		body := buf.Bytes()
+
+		requestModifiers = append(requestModifiers, func(r *http.Request) {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(body)), nil
+			}
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Type", c.Codec.ContentType())
+		})
+	}
+
+	res, err := c.Client.DoRaw(ctx, "POST", path.Join(version, method), headers, nil, nil, requestModifiers...)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if dst == nil {
+		return nil
+	}
+
+	if res.StatusCode == http.StatusNoContent {
+		return jsonclient.ErrNoResponse
+	}
+
+	// servers without support for the codec respond with JSON
+	codec := codecFor(res.Header.Get("Content-Type"))
+	if codec == nil {
+		codec = JSONCodec
+	}
+
+	if err := codec.Decode(res.Body, dst); err == io.EOF {
+		return jsonclient.ErrNoResponse
+	} else if err != nil {
+		return &ClientTransportError{method, version, "could not unmarshal", err}
+	}
+
+	return nil
+}
+
+// DoStream executes an RPC request against the configured server, returning
+// a Stream to decode each record of a streamed response. The caller must
+// close the Stream.
+func (c *Client) DoStream(ctx context.Context, method, version string, src interface{}, requestModifiers ...func(r *http.Request)) (*Stream, error) {
+	headers := http.Header{
+		"Accept": []string{StreamContentType},
+	}
+
+	requestModifiers = append([]func(r *http.Request){withRequestTimeout(ctx)}, requestModifiers...)
+
+	res, err := c.Client.DoRaw(ctx, "POST", path.Join(version, method), headers, nil, src, requestModifiers...)
+	if err != nil {
+		return nil, wrapClientError(method, version, err)
+	}
+
+	return newStream(res.Body), nil
+}
+
+func wrapClientError(method, version string, err error) error {
 	if err == nil {
 		return nil
 	}
@@ -56,6 +152,71 @@ func (c *Client) Do(ctx context.Context, method, version string, src, dst interf
 	return err
 }
 
+// errInvalidStreamRecord is returned by a Stream reading a record with neither
+// a result nor an error.
+var errInvalidStreamRecord = errors.New("crpc: invalid stream record")
+
+// Stream decodes the records of a streamed response.
+type Stream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+	err  error
+}
+
+func newStream(body io.ReadCloser) *Stream {
+	return &Stream{
+		body: body,
+		dec:  json.NewDecoder(body),
+	}
+}
+
+// Next decodes the next record of the stream into dst. It returns false when
+// the stream ends or an error occurs, which is returned by Err.
+func (s *Stream) Next(dst interface{}) bool {
+	if s.err != nil {
+		return false
+	}
+
+	var rec struct {
+		Result json.RawMessage `json:"result"`
+		Error  *cher.E         `json:"error"`
+	}
+
+	if err := s.dec.Decode(&rec); err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+
+		return false
+	}
+
+	if rec.Error != nil {
+		s.err = *rec.Error
+		return false
+	} else if rec.Result == nil {
+		s.err = errInvalidStreamRecord
+		return false
+	}
+
+	if err := json.Unmarshal(rec.Result, dst); err != nil {
+		s.err = err
+		return false
+	}
+
+	return true
+}
+
+// Err returns the first error encountered by Next, including any error the
+// server sent as the final record of the stream.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close closes the underlying response body.
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
+
 // ClientTransportError is returned when an error related to
 // executing a client request occurs.
 type ClientTransportError struct {
@@ -69,6 +230,11 @@ func (cte *ClientTransportError) Cause() error {
 	return cte.cause
 }
 
+// Unwrap returns the causal error (if wrapped) or nil
+func (cte *ClientTransportError) Unwrap() error {
+	return cte.cause
+}
+
 func (cte *ClientTransportError) Error() string {
 	if cte.cause != nil {
 		return fmt.Sprintf("%s/%s %s: %s", cte.Version, cte.Method, cte.ErrorString, cte.cause.Error())
@@ -0,0 +1,305 @@
+package crpc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// JSONContentType is the media type of JSON bodies, the default.
+	JSONContentType = "application/json"
+
+	// MsgPackContentType is the media type of MessagePack bodies.
+	MsgPackContentType = "application/msgpack"
+)
+
+// Codec encodes and decodes request and response bodies of a media type.
+// Codecs must honour `json` struct tags, so the same request and response
+// types can be used with every codec.
+type Codec interface {
+	// ContentType returns the media type of bodies encoded by the codec.
+	ContentType() string
+
+	// Encode writes the encoding of v to w.
+	Encode(w io.Writer, v interface{}) error
+
+	// Decode reads the next encoded value from r and stores it in v.
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	// JSONCodec encodes bodies as JSON with encoding/json.
+	JSONCodec Codec = jsonCodec{}
+
+	// MsgPackCodec encodes bodies as MessagePack, using `json` struct tags.
+	// Types which only implement json.Marshaler and json.Unmarshaler, such
+	// as ksuid.ID, are encoded as the MessagePack form of their JSON.
+	MsgPackCodec Codec = msgpackCodec{}
+)
+
+var codecs = map[string]Codec{
+	JSONContentType:    JSONCodec,
+	MsgPackContentType: MsgPackCodec,
+}
+
+// RegisterCodec adds a codec to those servers accept and respond with,
+// selected by the Content-Type and Accept headers of the request. It replaces
+// any codec already registered for the same media type. This function is not
+// thread safe and should be called during initialisation.
+func RegisterCodec(c Codec) {
+	codecs[c.ContentType()] = c
+}
+
+// codecFor returns the codec registered for a Content-Type header value, or
+// nil if there is none.
+func codecFor(contentType string) Codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	return codecs[mediaType]
+}
+
+// requestCodec returns the codec of the request body from its Content-Type,
+// defaulting to JSON.
+func requestCodec(r *Request) Codec {
+	if c := codecFor(r.GetHeader("Content-Type")); c != nil {
+		return c
+	}
+
+	return JSONCodec
+}
+
+// responseCodec returns the codec most preferred by the Accept header of the
+// request, defaulting to JSON.
+func responseCodec(r *Request) Codec {
+	best, bestQ := JSONCodec, 0.0
+
+	for _, part := range strings.Split(r.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		c, ok := codecs[mediaType]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+
+	return best
+}
+
+// jsonView re-encodes a body as JSON, so it can be validated against a JSON
+// schema whatever codec it was encoded with.
+func jsonView(c Codec, body []byte) ([]byte, error) {
+	if c == JSONCodec {
+		return body, nil
+	}
+
+	var v interface{}
+	if err := c.Decode(bytes.NewReader(body), &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// invalidBody returns the error for a body which could not be decoded by a
+// codec other than JSON.
+func invalidBody(c Codec, err error) error {
+	return cher.New("invalid_body", cher.M{"content_type": c.ContentType(), "error": err.Error()})
+}
+
+// setContentType sets the Content-Type of a response encoded by c. JSON
+// responses already have their Content-Type set by the server.
+func setContentType(w http.ResponseWriter, c Codec) {
+	if c != JSONCodec {
+		w.Header().Set("Content-Type", c.ContentType())
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return JSONContentType
+}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string {
+	return MsgPackContentType
+}
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	registerMsgPackJSONTypes(reflect.TypeOf(v))
+
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	registerMsgPackJSONTypes(reflect.TypeOf(v))
+
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	// msgpackEncodableTypes are the interfaces msgpack encodes types with
+	// in preference to their JSON encoding.
+	msgpackEncodableTypes = []reflect.Type{
+		reflect.TypeOf((*msgpack.CustomEncoder)(nil)).Elem(),
+		reflect.TypeOf((*msgpack.Marshaler)(nil)).Elem(),
+		reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem(),
+		reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+	}
+)
+
+// msgpackCheckedTypes are the types registerMsgPackJSONTypes has been called
+// with, so each is only walked once.
+var msgpackCheckedTypes sync.Map
+
+// registerMsgPackJSONTypes registers msgpack encoders and decoders for the
+// types reachable from t which only implement json.Marshaler and
+// json.Unmarshaler, so they are encoded the same way by both codecs. It must
+// be called before msgpack first encodes or decodes t, as msgpack caches the
+// encoders of struct fields.
+func registerMsgPackJSONTypes(t reflect.Type) {
+	if t == nil {
+		return
+	} else if _, checked := msgpackCheckedTypes.LoadOrStore(t, struct{}{}); checked {
+		return
+	}
+
+	walkMsgPackJSONTypes(t, make(map[reflect.Type]struct{}))
+}
+
+func walkMsgPackJSONTypes(t reflect.Type, seen map[reflect.Type]struct{}) {
+	if _, ok := seen[t]; ok {
+		return
+	}
+
+	seen[t] = struct{}{}
+
+	if usesJSONEncoding(t) {
+		msgpack.Register(reflect.Zero(t).Interface(), encodeMsgPackJSON, decodeMsgPackJSON)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		walkMsgPackJSONTypes(t.Elem(), seen)
+	case reflect.Map:
+		walkMsgPackJSONTypes(t.Key(), seen)
+		walkMsgPackJSONTypes(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() || f.Anonymous {
+				walkMsgPackJSONTypes(f.Type, seen)
+			}
+		}
+	}
+}
+
+// usesJSONEncoding returns true if t can only be encoded and decoded by its
+// JSON methods.
+func usesJSONEncoding(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+		return false
+	}
+
+	ptr := reflect.PtrTo(t)
+
+	if !ptr.Implements(jsonMarshalerType) || !ptr.Implements(jsonUnmarshalerType) {
+		return false
+	}
+
+	for _, it := range msgpackEncodableTypes {
+		if ptr.Implements(it) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func encodeMsgPackJSON(enc *msgpack.Encoder, v reflect.Value) error {
+	var m json.Marshaler
+	if v.CanAddr() {
+		m = v.Addr().Interface().(json.Marshaler)
+	} else if vm, ok := v.Interface().(json.Marshaler); ok {
+		m = vm
+	} else {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		m = ptr.Interface().(json.Marshaler)
+	}
+
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+
+	return enc.Encode(value)
+}
+
+func decodeMsgPackJSON(dec *msgpack.Decoder, v reflect.Value) error {
+	value, err := dec.DecodeInterface()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b)
+}
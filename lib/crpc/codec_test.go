@@ -0,0 +1,206 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/ksuid"
+	"github.com/cuvva/cuvva-public-go/lib/servicecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestResponseCodec(t *testing.T) {
+	tests := []struct {
+		Accept string
+		Codec  Codec
+	}{
+		{"", JSONCodec},
+		{"*/*", JSONCodec},
+		{"application/json", JSONCodec},
+		{"application/msgpack", MsgPackCodec},
+		{"application/msgpack, application/json;q=0.5", MsgPackCodec},
+		{"application/json, application/msgpack", JSONCodec},
+		{"application/json;q=0.5, application/msgpack", MsgPackCodec},
+		{"text/html, application/msgpack;q=0.9", MsgPackCodec},
+		{"application/msgpack;q=invalid", JSONCodec},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Accept, func(t *testing.T) {
+			r := &Request{Header: http.Header{"Accept": []string{test.Accept}}}
+
+			assert.Equal(t, test.Codec, responseCodec(r))
+		})
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			return next(w, r.WithContext(servicecontext.SetContext(r.Context(), "test", "test")))
+		}
+	})
+	zs.Use(ValidateResponse(gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"required": [ "message" ],
+		"properties": {
+			"message": { "type": "string", "minLength": 1 }
+		}
+	}`), 1))
+
+	Handle(zs, "greet", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		return &testResponse{Message: req.Name}, nil
+	})
+
+	encode := func(v interface{}) []byte {
+		b, err := msgpack.Marshal(v)
+		require.NoError(t, err)
+
+		return b
+	}
+
+	tests := []struct {
+		Name        string
+		ContentType string
+		Accept      string
+		Body        []byte
+
+		Status          int
+		ResponseType    string
+		ResponseMessage string
+	}{
+		{"MsgPack", MsgPackContentType, MsgPackContentType, encode(map[string]string{"name": "james"}), http.StatusOK, MsgPackContentType, "james"},
+		{"MsgPackRequest", MsgPackContentType, "", encode(map[string]string{"name": "james"}), http.StatusOK, "application/json; charset=utf-8", "james"},
+		{"MsgPackResponse", "application/json", MsgPackContentType, []byte(`{"name":"james"}`), http.StatusOK, MsgPackContentType, "james"},
+		{"Validated", MsgPackContentType, MsgPackContentType, encode(map[string]string{"other": "james"}), http.StatusBadRequest, "application/json; charset=utf-8", ""},
+		{"ResponseValidated", MsgPackContentType, MsgPackContentType, encode(map[string]string{"name": ""}), http.StatusInternalServerError, "application/json; charset=utf-8", ""},
+		{"Invalid", MsgPackContentType, MsgPackContentType, []byte{0xc1}, http.StatusBadRequest, "application/json; charset=utf-8", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/2019-01-01/greet", bytes.NewReader(test.Body))
+			r.Header.Set("Content-Type", test.ContentType)
+			r.Header.Set("Accept", test.Accept)
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.ResponseType, w.Header().Get("Content-Type"))
+
+			if test.ResponseMessage == "" {
+				return
+			}
+
+			var res testResponse
+			require.NoError(t, codecFor(test.ResponseType).Decode(w.Body, &res))
+			assert.Equal(t, test.ResponseMessage, res.Message)
+		})
+	}
+}
+
+type ksuidMessage struct {
+	ID  ksuid.ID   `json:"id"`
+	Ptr *ksuid.ID  `json:"ptr,omitempty"`
+	IDs []ksuid.ID `json:"ids"`
+}
+
+func TestMsgPackJSONTypes(t *testing.T) {
+	id := ksuid.MustParse("user_000000BPG6Lks9tQoAiJYrBRSXPX6")
+
+	zs := NewServer(UnsafeNoAuthentication)
+
+	Handle(zs, "echo", "2019-01-01", gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"additionalProperties": false,
+		"required": [ "id", "ids" ],
+		"properties": {
+			"id": { "type": "string" },
+			"ptr": { "type": "string" },
+			"ids": { "type": "array", "items": { "type": "string" } }
+		}
+	}`), func(_ context.Context, req *ksuidMessage) (*ksuidMessage, error) {
+		return req, nil
+	})
+
+	hs := httptest.NewServer(zs)
+	defer hs.Close()
+
+	c := NewClient(context.Background(), hs.URL+"/", nil)
+	c.Codec = MsgPackCodec
+
+	req := &ksuidMessage{ID: id, Ptr: &id, IDs: []ksuid.ID{id}}
+
+	var res ksuidMessage
+	require.NoError(t, c.Do(context.Background(), "echo", "2019-01-01", req, &res))
+	assert.Equal(t, *req, res)
+
+	t.Run("EncodedAsJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, MsgPackCodec.Encode(&buf, req))
+
+		var v map[string]interface{}
+		require.NoError(t, msgpack.Unmarshal(buf.Bytes(), &v))
+		assert.Equal(t, id.String(), v["id"])
+		assert.Equal(t, id.String(), v["ptr"])
+		assert.Equal(t, []interface{}{id.String()}, v["ids"])
+	})
+}
+
+func TestClientCodec(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	var contentType string
+
+	Handle(zs, "greet", "2019-01-01", catalogueSchema, func(ctx context.Context, req *handleRequest) (*testResponse, error) {
+		contentType = GetRequestContext(ctx).GetHeader("Content-Type")
+		return &testResponse{Message: "hello " + req.Name}, nil
+	})
+
+	hs := httptest.NewServer(zs)
+	defer hs.Close()
+
+	c := NewClient(context.Background(), hs.URL+"/", nil)
+	c.Codec = MsgPackCodec
+
+	var res testResponse
+	err := c.Do(context.Background(), "greet", "2019-01-01", &handleRequest{Name: "james"}, &res)
+	require.NoError(t, err)
+
+	assert.Equal(t, MsgPackContentType, contentType)
+	assert.Equal(t, "hello james", res.Message)
+
+	t.Run("Error", func(t *testing.T) {
+		err := c.Do(context.Background(), "greet", "2019-01-01", &struct{}{}, &res)
+
+		var cErr cher.E
+		if assert.ErrorAs(t, err, &cErr) {
+			assert.Equal(t, http.StatusBadRequest, cErr.StatusCode())
+		}
+	})
+
+	t.Run("JSONResponse", func(t *testing.T) {
+		js := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"message":"hello json"}`))
+		}))
+		defer js.Close()
+
+		c := NewClient(context.Background(), js.URL+"/", nil)
+		c.Codec = MsgPackCodec
+
+		var res testResponse
+		require.NoError(t, c.Do(context.Background(), "greet", "2019-01-01", &handleRequest{Name: "james"}, &res))
+		assert.Equal(t, "hello json", res.Message)
+	})
+}
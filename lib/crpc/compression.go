@@ -0,0 +1,218 @@
+package crpc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/httpcompress"
+)
+
+const (
+	// DefaultCompressionMinSize is the default smallest response body, in
+	// bytes, which is compressed.
+	DefaultCompressionMinSize = 1024
+
+	// DefaultMaxDecompressedSize is the default largest size, in bytes, a
+	// compressed request body may decompress to.
+	DefaultMaxDecompressedSize = 10 << 20
+)
+
+// CompressionConfig configures the compression of request and response
+// bodies with gzip or zstd, negotiated with the Accept-Encoding and
+// Content-Encoding headers.
+type CompressionConfig struct {
+	// MinSize is the smallest response body, in bytes, which is compressed.
+	// Smaller responses are not worth the overhead. Defaults to
+	// DefaultCompressionMinSize if zero.
+	MinSize int
+
+	// MaxDecompressedSize is the largest size, in bytes, a compressed request
+	// body may decompress to, guarding against zip bombs. Defaults to
+	// DefaultMaxDecompressedSize if zero.
+	MaxDecompressedSize int64
+}
+
+func (c *CompressionConfig) minSize() int {
+	if c.MinSize > 0 {
+		return c.MinSize
+	}
+
+	return DefaultCompressionMinSize
+}
+
+func (c *CompressionConfig) maxDecompressedSize() int64 {
+	if c.MaxDecompressedSize > 0 {
+		return c.MaxDecompressedSize
+	}
+
+	return DefaultMaxDecompressedSize
+}
+
+// decompressRequest replaces the body of a compressed request with its
+// decompressed body, limited to the maximum decompressed size.
+func (c *CompressionConfig) decompressRequest(r *http.Request) error {
+	encoding := strings.TrimSpace(r.Header.Get("Content-Encoding"))
+	if encoding == "" || strings.EqualFold(encoding, httpcompress.Identity) {
+		return nil
+	}
+
+	max := c.maxDecompressedSize()
+
+	body, err := httpcompress.NewReader(encoding, r.Body, max)
+	if errors.Is(err, httpcompress.ErrUnsupportedEncoding) {
+		return cher.New("unsupported_content_encoding", cher.M{"encoding": encoding})
+	} else if err != nil {
+		return cher.New("invalid_content_encoding", cher.M{"encoding": encoding})
+	}
+
+	r.Body = httpcompress.LimitReader(body, max, cher.New("request_too_large", cher.M{"max_size": max}))
+	r.Header.Del("Content-Encoding")
+	r.ContentLength = -1
+
+	return nil
+}
+
+// compressResponse returns a writer compressing the response with the
+// content coding preferred by the client, once it reaches the minimum size.
+// The returned function must be called once the response has been written.
+func (c *CompressionConfig) compressResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func() error) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := httpcompress.Negotiate(r.Header.Get("Accept-Encoding"))
+	if encoding == httpcompress.Identity {
+		return w, func() error { return nil }
+	}
+
+	cw := &compressWriter{
+		ResponseWriter: w,
+
+		encoding: encoding,
+		minSize:  c.minSize(),
+	}
+
+	return cw, cw.close
+}
+
+// compressWriter buffers the start of a response until it reaches the
+// minimum size to be compressed, or is flushed by a stream.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	started bool
+	cw      httpcompress.Writer
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+
+	w.status = status
+
+	// responses without a body are passed straight through
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		_ = w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.started {
+		if w.cw != nil {
+			return w.cw.Write(p)
+		}
+
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush starts compression of a response which is being streamed, even if
+// it has not reached the minimum size, then flushes it to the client.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+
+	if w.cw != nil {
+		if err := w.cw.Flush(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if compress && w.Header().Get("Content-Encoding") == "" {
+		cw, err := httpcompress.NewWriter(w.encoding, w.ResponseWriter)
+		if err != nil {
+			return err
+		}
+
+		w.cw = cw
+
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.Header().Del("Content-Length")
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+
+	w.buf = nil
+
+	return err
+}
+
+// close writes any buffered response, uncompressed if it did not reach the
+// minimum size, and completes the compressed response.
+func (w *compressWriter) close() error {
+	if !w.started {
+		if w.status == 0 && len(w.buf) == 0 {
+			return nil
+		}
+
+		return w.start(false)
+	}
+
+	if w.cw != nil {
+		return w.cw.Close()
+	}
+
+	return nil
+}
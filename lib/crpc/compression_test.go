@@ -0,0 +1,150 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/httpcompress"
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+
+	b, err := httpcompress.Compress(encoding, []byte(body))
+	require.NoError(t, err)
+
+	return b
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	r, err := httpcompress.NewReader(encoding, bytes.NewReader(body), DefaultMaxDecompressedSize)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return string(b)
+}
+
+func TestCompression(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.Compression = &CompressionConfig{MinSize: 100, MaxDecompressedSize: 1000}
+
+	Handle(zs, "echo", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		return &testResponse{Message: req.Name}, nil
+	})
+	HandleEmpty(zs, "ping", "2019-01-01", func(context.Context) error { return nil })
+	zs.Register("stream", "preview", nil, streamIterHandler(2, nil))
+
+	large := strings.Repeat("a", 200)
+
+	tests := []struct {
+		Name            string
+		Path            string
+		Body            string
+		ContentEncoding string
+		Compress        bool
+		AcceptEncoding  string
+
+		Status           int
+		ResponseEncoding string
+		Response         string
+	}{
+		{"Uncompressed", "/2019-01-01/echo", `{"name": "` + large + `"}`, "", false, "", http.StatusOK, "", `{"message":"` + large + `"}` + "\n"},
+		{"BelowMinSize", "/2019-01-01/echo", `{"name": "james"}`, "", false, "gzip", http.StatusOK, "", `{"message":"james"}` + "\n"},
+		{"Gzip", "/2019-01-01/echo", `{"name": "` + large + `"}`, "", false, "gzip", http.StatusOK, "gzip", `{"message":"` + large + `"}` + "\n"},
+		{"Zstd", "/2019-01-01/echo", `{"name": "` + large + `"}`, "", false, "gzip, zstd", http.StatusOK, "zstd", `{"message":"` + large + `"}` + "\n"},
+		{"GzipRequest", "/2019-01-01/echo", `{"name": "james"}`, "gzip", true, "", http.StatusOK, "", `{"message":"james"}` + "\n"},
+		{"ZstdRequest", "/2019-01-01/echo", `{"name": "james"}`, "zstd", true, "", http.StatusOK, "", `{"message":"james"}` + "\n"},
+		{"CompressedRequestValidated", "/2019-01-01/echo", `{}`, "gzip", true, "", http.StatusBadRequest, "", ""},
+		{"UnsupportedEncoding", "/2019-01-01/echo", `{"name": "james"}`, "br", false, "", http.StatusBadRequest, "", `{"code":"unsupported_content_encoding","meta":{"encoding":"br"}}` + "\n"},
+		{"InvalidEncoding", "/2019-01-01/echo", `{"name": "james"}`, "gzip", false, "", http.StatusBadRequest, "", `{"code":"invalid_content_encoding","meta":{"encoding":"gzip"}}` + "\n"},
+		{"ZipBomb", "/2019-01-01/echo", `{"name": "` + strings.Repeat("a", 2000) + `"}`, "gzip", true, "", http.StatusBadRequest, "", `{"code":"request_too_large","meta":{"max_size":1000}}` + "\n"},
+		{"NoContent", "/2019-01-01/ping", ``, "", false, "gzip", http.StatusNoContent, "", ""},
+		{"Stream", "/preview/stream", ``, "", false, "gzip", http.StatusOK, "gzip", "{\"result\":{\"message\":\"iter\"}}\n{\"result\":{\"message\":\"iter\"}}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(test.Body)
+			if test.Compress {
+				body = bytes.NewReader(compress(t, test.ContentEncoding, test.Body))
+			}
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", test.Path, body)
+			r.Header.Set("Content-Encoding", test.ContentEncoding)
+			r.Header.Set("Accept-Encoding", test.AcceptEncoding)
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.ResponseEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+			if test.Response == "" {
+				return
+			}
+
+			if test.ResponseEncoding != "" {
+				assert.Equal(t, test.Response, decompress(t, test.ResponseEncoding, w.Body.Bytes()))
+			} else {
+				assert.Equal(t, test.Response, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestClientCompression(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.Compression = &CompressionConfig{MinSize: 100}
+
+	var contentEncoding string
+
+	Handle(zs, "echo", "2019-01-01", catalogueSchema, func(ctx context.Context, req *handleRequest) (*testResponse, error) {
+		contentEncoding = GetRequestContext(ctx).GetHeader("X-Original-Content-Encoding")
+		return &testResponse{Message: req.Name}, nil
+	})
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-Original-Content-Encoding", r.Header.Get("Content-Encoding"))
+		zs.ServeHTTP(w, r)
+	}))
+	defer hs.Close()
+
+	large := strings.Repeat("a", 200)
+
+	for _, encoding := range []string{httpcompress.Gzip, httpcompress.Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			c := NewClient(context.Background(), hs.URL+"/", nil)
+			c.Compression = &jsonclient.CompressionConfig{RequestEncoding: encoding, MinSize: 100}
+
+			var res testResponse
+			err := c.Do(context.Background(), "echo", "2019-01-01", &handleRequest{Name: large}, &res)
+			require.NoError(t, err)
+
+			assert.Equal(t, large, res.Message)
+			assert.Equal(t, encoding, contentEncoding)
+		})
+	}
+
+	t.Run("ResponseTooLarge", func(t *testing.T) {
+		c := NewClient(context.Background(), hs.URL+"/", nil)
+		c.Compression = &jsonclient.CompressionConfig{MaxDecompressedSize: 100}
+
+		var res testResponse
+		err := c.Do(context.Background(), "echo", "2019-01-01", &handleRequest{Name: large}, &res)
+		assert.ErrorIs(t, err, jsonclient.ErrResponseTooLarge)
+	})
+}
@@ -0,0 +1,338 @@
+package crpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/internal/promutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultConcurrencyInitialLimit is the default number of requests
+	// allowed in flight before the limit has adapted to observed latency.
+	DefaultConcurrencyInitialLimit = 20
+
+	// DefaultConcurrencyMaxLimit is the default ceiling the limit can
+	// increase to.
+	DefaultConcurrencyMaxLimit = 200
+
+	// DefaultConcurrencyBackoff is the default ratio the limit is multiplied
+	// by when a request is slower than the latency target.
+	DefaultConcurrencyBackoff = 0.9
+)
+
+// ConcurrencyConfig configures the limit on requests in flight applied by
+// LimitConcurrency.
+type ConcurrencyConfig struct {
+	// Key groups requests sharing a limit. Defaults to KeyByMethod, giving
+	// each method and version its own limit.
+	Key KeyFunc
+
+	// InitialLimit is the number of requests allowed in flight to begin with.
+	// Defaults to DefaultConcurrencyInitialLimit if zero.
+	InitialLimit int
+
+	// MinLimit and MaxLimit bound the adaptive limit. They default to 1 and
+	// DefaultConcurrencyMaxLimit if zero.
+	MinLimit, MaxLimit int
+
+	// LatencyTarget is the request duration above which the limit is
+	// decreased. If zero, the limit only decreases when requests time out.
+	LatencyTarget time.Duration
+
+	// Backoff is the ratio the limit is multiplied by when a request is
+	// slower than LatencyTarget, times out or panics. Defaults to
+	// DefaultConcurrencyBackoff if zero.
+	Backoff float64
+
+	// QueueSize is the number of requests which may wait for a request in
+	// flight to complete once the limit is reached, for up to QueueTimeout.
+	// If zero, requests are rejected as soon as the limit is reached.
+	QueueSize int
+
+	// QueueTimeout is the longest a request waits in the queue. If zero,
+	// requests wait until their context is done.
+	QueueTimeout time.Duration
+
+	// Registerer, if set, is used to register metrics of the requests in
+	// flight, the current limits and the requests rejected. It is typically
+	// the same Registerer given to Instrument.
+	Registerer prometheus.Registerer
+}
+
+// LimitConcurrency caps the number of requests in flight, shedding requests
+// beyond the limit with a retryable unavailable error and a Retry-After
+// header, so a slow downstream cannot exhaust the resources of every method.
+//
+// The limit adapts to observed latency with additive increase, multiplicative
+// decrease: it grows by one for each limit's worth of requests which complete
+// within LatencyTarget while the limit is being approached, and shrinks by
+// Backoff whenever a request exceeds LatencyTarget or times out.
+func LimitConcurrency(cfg ConcurrencyConfig) MiddlewareFunc {
+	key := cfg.Key
+	if key == nil {
+		key = KeyByMethod
+	}
+
+	m := newConcurrencyMetrics(cfg.Registerer)
+
+	var mu sync.Mutex
+	limits := map[string]*concurrencyLimit{}
+
+	get := func(k string) *concurrencyLimit {
+		mu.Lock()
+		defer mu.Unlock()
+
+		l, ok := limits[k]
+		if !ok {
+			l = newConcurrencyLimit(cfg)
+			limits[k] = l
+		}
+
+		return l
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) (err error) {
+			k := key(r)
+			l := get(k)
+
+			if !l.acquire(r.Context()) {
+				m.shed(r)
+
+				w.Header().Set("Retry-After", "1")
+
+				return cher.New(cher.Unavailable, nil, cher.New("concurrency_limit_exceeded", cher.M{"limit": l.current()}))
+			}
+
+			m.start(r)
+			start := time.Now()
+
+			// the place in flight is released even if the handler panics,
+			// which counts as a failed request
+			failed := true
+			defer func() {
+				l.release(time.Since(start), failed)
+				m.finish(r, l.current())
+			}()
+
+			err = next(w, r)
+			failed = isTimeout(err)
+
+			return err
+		}
+	}
+}
+
+// isTimeout reports whether a request failed because it, or a request it
+// made, ran out of time.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if e, ok := cher.Translate(err); ok {
+		return e.Code == cher.RequestTimeout || e.Code == cher.ThirdPartyTimeout
+	}
+
+	return false
+}
+
+// concurrencyLimit tracks the requests in flight against an adaptive limit,
+// and the requests queued waiting for one to complete.
+type concurrencyLimit struct {
+	mu sync.Mutex
+
+	limit    float64
+	min, max float64
+	target   time.Duration
+	backoff  float64
+
+	inflight     int
+	queue        []chan struct{}
+	queueSize    int
+	queueTimeout time.Duration
+}
+
+func newConcurrencyLimit(cfg ConcurrencyConfig) *concurrencyLimit {
+	l := &concurrencyLimit{
+		limit:        float64(cfg.InitialLimit),
+		min:          float64(cfg.MinLimit),
+		max:          float64(cfg.MaxLimit),
+		target:       cfg.LatencyTarget,
+		backoff:      cfg.Backoff,
+		queueSize:    cfg.QueueSize,
+		queueTimeout: cfg.QueueTimeout,
+	}
+
+	if l.limit <= 0 {
+		l.limit = DefaultConcurrencyInitialLimit
+	}
+	if l.min <= 0 {
+		l.min = 1
+	}
+	if l.max <= 0 {
+		l.max = DefaultConcurrencyMaxLimit
+	}
+	if l.backoff <= 0 || l.backoff >= 1 {
+		l.backoff = DefaultConcurrencyBackoff
+	}
+
+	return l
+}
+
+func (l *concurrencyLimit) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// acquire reserves a place in flight, waiting in the queue if the limit has
+// been reached and there is room. It returns false if the request is shed.
+func (l *concurrencyLimit) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+
+	if l.inflight < int(l.limit) {
+		l.inflight++
+		l.mu.Unlock()
+		return true
+	}
+
+	if len(l.queue) >= l.queueSize {
+		l.mu.Unlock()
+		return false
+	}
+
+	ready := make(chan struct{})
+	l.queue = append(l.queue, ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return true
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, q := range l.queue {
+		if q == ready {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			return false
+		}
+	}
+
+	// the place was handed over while giving up, so it is kept
+	return true
+}
+
+// release frees a place in flight, handing it to the next queued request if
+// the limit allows, and adapts the limit to the request's latency. Requests
+// which failed, by timing out or panicking, decrease the limit.
+func (l *concurrencyLimit) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if failed || (l.target > 0 && latency > l.target) {
+		l.limit *= l.backoff
+		if l.limit < l.min {
+			l.limit = l.min
+		}
+	} else if float64(l.inflight)*2 >= l.limit {
+		// only grow while the limit is being approached, so an idle limit
+		// does not drift up to the maximum
+		l.limit += 1 / l.limit
+		if l.limit > l.max {
+			l.limit = l.max
+		}
+	}
+
+	if len(l.queue) > 0 && l.inflight <= int(l.limit) {
+		close(l.queue[0])
+		l.queue = l.queue[1:]
+		return
+	}
+
+	l.inflight--
+}
+
+type concurrencyMetrics struct {
+	inflight *prometheus.GaugeVec
+	limit    *prometheus.GaugeVec
+	shedding *prometheus.CounterVec
+}
+
+func newConcurrencyMetrics(r prometheus.Registerer) *concurrencyMetrics {
+	if r == nil {
+		return nil
+	}
+
+	m := &concurrencyMetrics{
+		inflight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "rpc_in_flight_requests",
+				Help: "Number of RPC requests in flight",
+			},
+			[]string{"method", "version"},
+		),
+		limit: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "rpc_concurrency_limit",
+				Help: "Current adaptive limit on RPC requests in flight, as last observed for the method",
+			},
+			[]string{"method", "version"},
+		),
+		shedding: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "rpc_shed_request_total",
+				Help: "Total number of RPC requests rejected by the concurrency limit",
+			},
+			[]string{"method", "version"},
+		),
+	}
+
+	// reuse metrics already registered by another LimitConcurrency, so it can
+	// be given to Register for more than one method
+	m.inflight = promutil.RegisterOrExisting(r, m.inflight).(*prometheus.GaugeVec)
+	m.limit = promutil.RegisterOrExisting(r, m.limit).(*prometheus.GaugeVec)
+	m.shedding = promutil.RegisterOrExisting(r, m.shedding).(*prometheus.CounterVec)
+
+	return m
+}
+
+func (m *concurrencyMetrics) start(r *Request) {
+	if m != nil {
+		m.inflight.WithLabelValues(r.Method, r.Version).Inc()
+	}
+}
+
+// finish labels the limit by method rather than limiter key, as keys such as
+// KeyByPrincipal are unbounded.
+func (m *concurrencyMetrics) finish(r *Request, limit int) {
+	if m != nil {
+		m.inflight.WithLabelValues(r.Method, r.Version).Dec()
+		m.limit.WithLabelValues(r.Method, r.Version).Set(float64(limit))
+	}
+}
+
+func (m *concurrencyMetrics) shed(r *Request) {
+	if m != nil {
+		m.shedding.WithLabelValues(r.Method, r.Version).Inc()
+	}
+}
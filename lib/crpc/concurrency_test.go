@@ -0,0 +1,229 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	call := func(zs *Server, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", path, nil)
+
+		zs.ServeHTTP(w, r)
+
+		return w
+	}
+
+	// block holds requests in flight until the returned function is called
+	block := func(zs *Server, path string, n int, started chan struct{}) (wait func()) {
+		var wg sync.WaitGroup
+		wg.Add(n)
+
+		for i := 0; i < n; i++ {
+			go func() {
+				defer wg.Done()
+				call(zs, path)
+			}()
+
+			<-started
+		}
+
+		return wg.Wait
+	}
+
+	t.Run("Shed", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		started, unblock := make(chan struct{}), make(chan struct{})
+
+		mw := LimitConcurrency(ConcurrencyConfig{InitialLimit: 2, Registerer: reg})
+
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Register("slow", "2019-01-01", nil, func(context.Context) error {
+			started <- struct{}{}
+			<-unblock
+			return nil
+		}, mw)
+		zs.Register("fast", "2019-01-01", nil, func(context.Context) error { return nil }, LimitConcurrency(ConcurrencyConfig{Registerer: reg}))
+
+		wait := block(zs, "/2019-01-01/slow", 2, started)
+
+		w := call(zs, "/2019-01-01/slow")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"code":"unavailable","reasons":[{"code":"concurrency_limit_exceeded","meta":{"limit":2}}]}`, w.Body.String())
+
+		// other methods have their own limit
+		w = call(zs, "/2019-01-01/fast")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		close(unblock)
+		wait()
+
+		go func() { <-started }()
+
+		w = call(zs, "/2019-01-01/slow")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+
+		values := map[string]float64{}
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				// labelled by method, never by limiter key
+				var labels []string
+				for _, l := range m.GetLabel() {
+					labels = append(labels, l.GetName())
+				}
+				assert.Equal(t, []string{"method", "version"}, labels, mf.GetName())
+
+				switch {
+				case m.GetCounter() != nil:
+					values[mf.GetName()] += m.GetCounter().GetValue()
+				case m.GetGauge() != nil:
+					values[mf.GetName()] += m.GetGauge().GetValue()
+				}
+			}
+		}
+
+		assert.Equal(t, float64(1), values["rpc_shed_request_total"])
+		assert.Equal(t, float64(0), values["rpc_in_flight_requests"])
+		assert.Contains(t, values, "rpc_concurrency_limit")
+	})
+
+	t.Run("QueueTimeout", func(t *testing.T) {
+		started, unblock := make(chan struct{}), make(chan struct{})
+
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Register("slow", "2019-01-01", nil, func(context.Context) error {
+			started <- struct{}{}
+			<-unblock
+			return nil
+		}, LimitConcurrency(ConcurrencyConfig{InitialLimit: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond}))
+
+		wait := block(zs, "/2019-01-01/slow", 1, started)
+
+		w := call(zs, "/2019-01-01/slow")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		close(unblock)
+		wait()
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Use(Recover(prometheus.NewRegistry(), nil))
+		zs.Register("boom", "2019-01-01", nil, func(context.Context) error {
+			panic("boom")
+		}, LimitConcurrency(ConcurrencyConfig{InitialLimit: 2, MaxLimit: 2}))
+
+		for i := 0; i < 3; i++ {
+			w := call(zs, "/2019-01-01/boom")
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+			assert.Contains(t, w.Body.String(), cher.Unknown)
+		}
+	})
+}
+
+func TestConcurrencyLimitAdapts(t *testing.T) {
+	l := newConcurrencyLimit(ConcurrencyConfig{
+		InitialLimit:  10,
+		MinLimit:      2,
+		MaxLimit:      12,
+		LatencyTarget: 100 * time.Millisecond,
+		Backoff:       0.5,
+	})
+
+	ctx := context.Background()
+
+	// fast requests near the limit slowly increase it
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 10; i++ {
+			require.True(t, l.acquire(ctx))
+		}
+		for i := 0; i < 10; i++ {
+			l.release(10*time.Millisecond, false)
+		}
+	}
+	assert.Equal(t, 11, l.current())
+
+	// fast requests well below the limit leave it alone
+	for i := 0; i < 20; i++ {
+		require.True(t, l.acquire(ctx))
+		l.release(10*time.Millisecond, false)
+	}
+	assert.Equal(t, 11, l.current())
+
+	// slow or timed out requests halve it, down to the minimum
+	require.True(t, l.acquire(ctx))
+	l.release(time.Second, false)
+	assert.Equal(t, 5, l.current())
+
+	require.True(t, l.acquire(ctx))
+	l.release(10*time.Millisecond, true)
+	assert.Equal(t, 2, l.current())
+
+	require.True(t, l.acquire(ctx))
+	l.release(time.Second, false)
+	assert.Equal(t, 2, l.current())
+}
+
+func TestConcurrencyLimitQueue(t *testing.T) {
+	l := newConcurrencyLimit(ConcurrencyConfig{InitialLimit: 1, MaxLimit: 1, QueueSize: 1})
+
+	ctx := context.Background()
+
+	queueLen := func() int {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		return len(l.queue)
+	}
+
+	require.True(t, l.acquire(ctx))
+
+	queued := make(chan bool)
+	go func() { queued <- l.acquire(ctx) }()
+
+	assert.Eventually(t, func() bool { return queueLen() == 1 }, time.Second, time.Millisecond)
+
+	// the queue is full
+	assert.False(t, l.acquire(ctx))
+
+	// the place is handed to the queued request
+	l.release(0, false)
+	assert.True(t, <-queued)
+	assert.Equal(t, 1, l.inflight)
+
+	// queued requests give up when their context is done
+	ctx, cancel := context.WithCancel(ctx)
+	go func() { queued <- l.acquire(ctx) }()
+
+	assert.Eventually(t, func() bool { return queueLen() == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.False(t, <-queued)
+
+	l.release(0, false)
+	assert.Equal(t, 0, l.inflight)
+	assert.Equal(t, 0, queueLen())
+}
+
+func TestIsTimeout(t *testing.T) {
+	assert.True(t, isTimeout(context.DeadlineExceeded))
+	assert.True(t, isTimeout(cher.New(cher.RequestTimeout, nil)))
+	assert.True(t, isTimeout(cher.New(cher.ThirdPartyTimeout, nil)))
+	assert.False(t, isTimeout(cher.New(cher.NotFound, nil)))
+	assert.False(t, isTimeout(nil))
+}
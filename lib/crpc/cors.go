@@ -0,0 +1,148 @@
+package crpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the Cross-Origin Resource Sharing headers which allow
+// browsers on other origins to call the server.
+type CORSConfig struct {
+	// AllowedOrigins are the origins browsers may call from, either exactly,
+	// such as "https://app.cuvva.com", or any subdomain of a domain, such as
+	// "https://*.cuvva.com". "*" allows any origin.
+	AllowedOrigins []string
+
+	// AllowedHeaders are the request headers browsers may send, in addition
+	// to Content-Type, such as Authorization.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers browsers may read, in addition
+	// to the CORS-safelisted response headers, such as Cuvva-Endpoint-Status.
+	ExposedHeaders []string
+
+	// AllowCredentials allows browsers to send cookies and other credentials.
+	// Credentials are only allowed for origins listed explicitly or matching
+	// a wildcard subdomain, never for origins allowed only by "*".
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache the response to a preflight
+	// request. Browsers apply their own default if zero.
+	MaxAge time.Duration
+}
+
+// DenyBrowserAccess prevents browsers calling a registered method and
+// version, rejecting requests with an Origin header with access_denied, even
+// if their origin is allowed by CORS. Later versions which inherit the
+// method are also denied, until it is registered again. If the method has
+// not been registered, DenyBrowserAccess will panic.
+func (s *Server) DenyBrowserAccess(method, version string) {
+	s.mustGetHandler(method, version).browserDenied = true
+}
+
+// allowsOrigin reports whether browsers may call from origin, and whether
+// origin is allowed only because any origin is.
+func (c *CORSConfig) allowsOrigin(origin string) (allowed, anyOrigin bool) {
+	if origin == "" {
+		return false, false
+	}
+
+	origin = strings.ToLower(origin)
+
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+
+		if allowed == "*" {
+			anyOrigin = true
+			continue
+		} else if allowed == origin {
+			return true, false
+		}
+
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if !ok || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+
+		// the wildcard only matches subdomain labels
+		subdomain := origin[len(prefix) : len(origin)-len(suffix)]
+		if len(origin) > len(prefix)+len(suffix) && strings.Trim(subdomain, "abcdefghijklmnopqrstuvwxyz0123456789-.") == "" {
+			return true, false
+		}
+	}
+
+	return anyOrigin, anyOrigin
+}
+
+// appendCORSHeaders applies the headers allowing the browser origin of the
+// request to read the response, if it is allowed.
+func (c *CORSConfig) appendCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+
+	allowed, anyOrigin := c.allowsOrigin(origin)
+	if !allowed {
+		return false
+	}
+
+	switch {
+	case anyOrigin && c.AllowCredentials:
+		// echoing the origin would let any site make credentialed calls, so
+		// "*" is sent, which browsers never send credentials to
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	case c.AllowCredentials:
+		// the origin is echoed rather than "*", which browsers reject when
+		// credentials are allowed
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+	default:
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if len(c.ExposedHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+
+	return true
+}
+
+// servePreflight answers a preflight request sent by a browser before it
+// calls a method from another origin.
+func (s *Server) servePreflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if !s.CORS.appendCORSHeaders(w, r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if method, version, ok := requestPath(r.URL.Path); ok {
+		if hn := s.resolvedMethods[version][method]; hn != nil && hn.browserDenied {
+			w.Header().Del("Access-Control-Allow-Origin")
+			w.Header().Del("Access-Control-Allow-Credentials")
+			w.Header().Del("Access-Control-Expose-Headers")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", "POST")
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(append([]string{"Content-Type"}, s.CORS.AllowedHeaders...), ", "))
+
+	if s.CORS.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(s.CORS.MaxAge/time.Second)))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
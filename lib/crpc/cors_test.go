@@ -0,0 +1,184 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowsOrigin(t *testing.T) {
+	c := &CORSConfig{AllowedOrigins: []string{"https://app.cuvva.com", "https://*.cuvva.dev"}}
+
+	tests := []struct {
+		Origin  string
+		Allowed bool
+	}{
+		{"https://app.cuvva.com", true},
+		{"https://APP.cuvva.com", true},
+		{"https://other.cuvva.com", false},
+		{"http://app.cuvva.com", false},
+		{"https://web.cuvva.dev", true},
+		{"https://a.b.cuvva.dev", true},
+		{"https://cuvva.dev", false},
+		{"https://.cuvva.dev", false},
+		{"https://evil.com/.cuvva.dev", false},
+		{"https://evil.com:443.cuvva.dev", false},
+		{"http://web.cuvva.dev", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Origin, func(t *testing.T) {
+			allowed, anyOrigin := c.allowsOrigin(test.Origin)
+			assert.Equal(t, test.Allowed, allowed)
+			assert.False(t, anyOrigin)
+		})
+	}
+
+	t.Run("Any", func(t *testing.T) {
+		c := &CORSConfig{AllowedOrigins: []string{"*", "https://app.cuvva.com"}}
+
+		allowed, anyOrigin := c.allowsOrigin("https://example.com")
+		assert.True(t, allowed)
+		assert.True(t, anyOrigin)
+
+		allowed, anyOrigin = c.allowsOrigin("https://app.cuvva.com")
+		assert.True(t, allowed)
+		assert.False(t, anyOrigin)
+
+		allowed, _ = c.allowsOrigin("")
+		assert.False(t, allowed)
+	})
+}
+
+func TestCORS(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.CORS = &CORSConfig{
+		AllowedOrigins:   []string{"https://*.cuvva.com"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"Cuvva-Endpoint-Status"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	Handle(zs, "greet", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		return &testResponse{Message: "hello " + req.Name}, nil
+	})
+	Handle(zs, "internal", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		return &testResponse{Message: "hello " + req.Name}, nil
+	})
+	zs.DenyBrowserAccess("internal", "2019-01-01")
+
+	tests := []struct {
+		Name   string
+		Method string
+		Path   string
+		Origin string
+
+		Status      int
+		AllowOrigin string
+	}{
+		{"Preflight", "OPTIONS", "/2019-01-01/greet", "https://web.cuvva.com", http.StatusNoContent, "https://web.cuvva.com"},
+		{"PreflightUnknownMethod", "OPTIONS", "/2019-01-01/unknown", "https://web.cuvva.com", http.StatusNoContent, "https://web.cuvva.com"},
+		{"PreflightOriginDenied", "OPTIONS", "/2019-01-01/greet", "https://evil.com", http.StatusForbidden, ""},
+		{"PreflightBrowserDenied", "OPTIONS", "/2019-01-01/internal", "https://web.cuvva.com", http.StatusForbidden, ""},
+		{"Call", "POST", "/2019-01-01/greet", "https://web.cuvva.com", http.StatusOK, "https://web.cuvva.com"},
+		{"CallError", "POST", "/2019-01-01/unknown", "https://web.cuvva.com", http.StatusNotFound, "https://web.cuvva.com"},
+		{"CallOriginDenied", "POST", "/2019-01-01/greet", "https://evil.com", http.StatusOK, ""},
+		{"CallBrowserDenied", "POST", "/2019-01-01/internal", "https://web.cuvva.com", http.StatusForbidden, "https://web.cuvva.com"},
+		{"CallWithoutOrigin", "POST", "/2019-01-01/internal", "", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(test.Method, test.Path, strings.NewReader(`{"name":"james"}`))
+			if test.Origin != "" {
+				r.Header.Set("Origin", test.Origin)
+			}
+			if test.Method == "OPTIONS" {
+				r.Header.Set("Access-Control-Request-Method", "POST")
+				r.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+			}
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.AllowOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+			if test.Status == http.StatusNoContent {
+				assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+				assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+				assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+			}
+
+			if test.AllowOrigin != "" {
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+				assert.Equal(t, "Cuvva-Endpoint-Status", w.Header().Get("Access-Control-Expose-Headers"))
+			}
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		zs := NewServer(UnsafeNoAuthentication)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("OPTIONS", "/2019-01-01/greet", nil)
+		r.Header.Set("Origin", "https://web.cuvva.com")
+		r.Header.Set("Access-Control-Request-Method", "POST")
+
+		zs.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("AnyOriginWithCredentials", func(t *testing.T) {
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.CORS = &CORSConfig{
+			AllowedOrigins:   []string{"*", "https://app.cuvva.com"},
+			AllowCredentials: true,
+		}
+
+		Handle(zs, "greet", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+			return &testResponse{Message: "hello " + req.Name}, nil
+		})
+
+		for _, method := range []string{"OPTIONS", "POST"} {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(method, "/2019-01-01/greet", strings.NewReader(`{"name":"james"}`))
+			r.Header.Set("Origin", "https://evil.example")
+			r.Header.Set("Access-Control-Request-Method", "POST")
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"), method)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"), method)
+		}
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/greet", strings.NewReader(`{"name":"james"}`))
+		r.Header.Set("Origin", "https://app.cuvva.com")
+
+		zs.ServeHTTP(w, r)
+
+		assert.Equal(t, "https://app.cuvva.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("Catalogue", func(t *testing.T) {
+		methods := map[string]bool{}
+		for _, mi := range zs.Catalogue().Versions["2019-01-01"] {
+			methods[mi.Method] = mi.BrowserAccessDenied
+		}
+
+		assert.Equal(t, map[string]bool{"greet": false, "internal": true}, methods)
+	})
+}
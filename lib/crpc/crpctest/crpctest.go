@@ -0,0 +1,124 @@
+// Package crpctest provides utilities for testing crpc servers and handlers
+// in-process, without opening sockets.
+package crpctest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+)
+
+// baseURL is the address of every in-memory server. Requests are never sent
+// over the network, so the host is arbitrary.
+const baseURL = "http://crpctest/"
+
+// Server is a crpc.Server for use in tests, with clients which serve
+// requests in-process.
+type Server struct {
+	*crpc.Server
+
+	t testing.TB
+}
+
+// NewServer returns a new in-memory Server authenticating requests with auth,
+// typically NoAuthentication or StubAuthentication. If auth is nil,
+// NoAuthentication is used.
+func NewServer(t testing.TB, auth crpc.MiddlewareFunc) *Server {
+	if auth == nil {
+		auth = NoAuthentication
+	}
+
+	return &Server{
+		Server: crpc.NewServer(auth),
+
+		t: t,
+	}
+}
+
+// Client returns a crpc.Client sending requests to the server in-process.
+func (s *Server) Client() *crpc.Client {
+	return NewClient(s.Server)
+}
+
+// GoldenClient returns a crpc.Client sending requests to the server
+// in-process, recording each request and response to a golden file in
+// testdata named after the test. When the test completes, it fails if the
+// responses differ from the golden file. Run tests with -crpctest.update
+// to rewrite golden files.
+func (s *Server) GoldenClient() *crpc.Client {
+	return newClient(Golden(s.t, Transport(s.Server)))
+}
+
+// NewClient returns a crpc.Client sending requests to h in-process.
+func NewClient(h http.Handler) *crpc.Client {
+	return newClient(Transport(h))
+}
+
+func newClient(rt http.RoundTripper) *crpc.Client {
+	return crpc.NewClient(context.Background(), baseURL, &http.Client{
+		Transport: rt,
+	})
+}
+
+// Transport returns an http.RoundTripper serving requests with h in-process.
+func Transport(h http.Handler) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.RequestURI = req.URL.RequestURI()
+
+		if req.Body == nil {
+			req.Body = http.NoBody
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		res := w.Result()
+		res.Request = req
+
+		return res, nil
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// NoAuthentication passes all requests through without authentication.
+func NoAuthentication(next crpc.HandlerFunc) crpc.HandlerFunc {
+	return next
+}
+
+// Principal is a stub crpc.AuthorizedPrincipal with the given subject,
+// scopes and roles.
+type Principal struct {
+	ID            string
+	GrantedScopes []string
+	GrantedRoles  []string
+}
+
+// Subject returns the ID of the principal.
+func (p Principal) Subject() string { return p.ID }
+
+// Scopes returns the scopes granted to the principal.
+func (p Principal) Scopes() []string { return p.GrantedScopes }
+
+// Roles returns the roles granted to the principal.
+func (p Principal) Roles() []string { return p.GrantedRoles }
+
+// StubAuthentication authenticates every request as p.
+func StubAuthentication(p crpc.Principal) crpc.MiddlewareFunc {
+	return func(next crpc.HandlerFunc) crpc.HandlerFunc {
+		return func(w http.ResponseWriter, r *crpc.Request) error {
+			crpc.SetPrincipal(r, p)
+
+			return next(w, r)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package crpctest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greetRequest struct {
+	Name string `json:"name"`
+}
+
+type greetResponse struct {
+	Message string `json:"message"`
+}
+
+func TestClient(t *testing.T) {
+	s := NewServer(t, StubAuthentication(Principal{ID: "user_1", GrantedScopes: []string{"greet"}}))
+
+	crpc.HandleNoRequest(s.Server, "whoami", "2019-01-01", func(ctx context.Context) (*greetResponse, error) {
+		return &greetResponse{Message: crpc.GetPrincipal(ctx).Subject()}, nil
+	})
+	crpc.HandleNoRequest(s.Server, "admin", "2019-01-01", func(ctx context.Context) (*greetResponse, error) {
+		return &greetResponse{}, nil
+	})
+	s.RequirePermissions("admin", "2019-01-01", crpc.Permissions{Scopes: []string{"admin"}})
+
+	var res greetResponse
+	err := s.Client().Do(context.Background(), "whoami", "2019-01-01", nil, &res)
+	require.NoError(t, err)
+	assert.Equal(t, "user_1", res.Message)
+
+	err = s.Client().Do(context.Background(), "admin", "2019-01-01", nil, &res)
+	if assert.IsType(t, cher.E{}, err) {
+		assert.Equal(t, cher.AccessDenied, err.(cher.E).Code)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	newServer := func(t testing.TB, greeting string) *Server {
+		s := NewServer(t, nil)
+
+		s.Register("greet", "2019-01-01", nil, func(_ context.Context) (*greetResponse, error) {
+			return &greetResponse{Message: greeting}, nil
+		})
+
+		return s
+	}
+
+	call := func(t testing.TB, s *Server) {
+		var res greetResponse
+		err := s.GoldenClient().Do(context.Background(), "greet", "2019-01-01", nil, &res)
+		require.NoError(t, err)
+	}
+
+	run := func(greeting string, record bool) bool {
+		*update = record
+		defer func() { *update = false }()
+
+		rec := &recordingTB{TB: t, name: "TestGolden/greet"}
+		call(rec, newServer(rec, greeting))
+		rec.cleanup()
+
+		return rec.failed
+	}
+
+	assert.False(t, run("hello", true))
+
+	b, err := os.ReadFile(filepath.Join("testdata", "TestGolden_greet.golden"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"request": {"path": "/2019-01-01/greet"}, "response": {"status": 200, "body": {"message": "hello"}}}]`, string(b))
+
+	assert.False(t, run("hello", false), "unchanged response should pass")
+	assert.True(t, run("goodbye", false), "changed response should fail")
+}
+
+// recordingTB captures failures and cleanups, so the failure of a test can
+// itself be tested.
+type recordingTB struct {
+	testing.TB
+
+	name     string
+	failed   bool
+	cleanups []func()
+}
+
+func (r *recordingTB) Name() string                      { return r.name }
+func (r *recordingTB) Cleanup(fn func())                 { r.cleanups = append(r.cleanups, fn) }
+func (r *recordingTB) Errorf(format string, args ...any) { r.failed = true }
+func (r *recordingTB) Fatalf(format string, args ...any) { r.failed = true }
+func (r *recordingTB) Helper()                           {}
+
+func (r *recordingTB) cleanup() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestTransport(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.RemoteAddr)
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	res, err := (&http.Client{Transport: Transport(h)}).Get(baseURL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+}
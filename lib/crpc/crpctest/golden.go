@@ -0,0 +1,161 @@
+package crpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var update = flag.Bool("crpctest.update", false, "rewrite crpctest golden files")
+
+// Exchange is a request and response recorded to a golden file.
+type Exchange struct {
+	Request  ExchangeRequest  `json:"request"`
+	Response ExchangeResponse `json:"response"`
+}
+
+// ExchangeRequest is a recorded request.
+type ExchangeRequest struct {
+	Path string          `json:"path"`
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// ExchangeResponse is a recorded response.
+type ExchangeResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Golden returns an http.RoundTripper recording each request and response
+// made through rt. When the test completes, it fails if the exchanges differ
+// from the golden file testdata/<test name>.golden. Run tests with
+// -crpctest.update to rewrite golden files.
+func Golden(t testing.TB, rt http.RoundTripper) http.RoundTripper {
+	g := &golden{
+		path: filepath.Join("testdata", strings.ReplaceAll(t.Name(), "/", "_")+".golden"),
+	}
+
+	t.Cleanup(func() {
+		g.check(t)
+	})
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		reqBody, err := readBody(&req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resBody, err := readBody(&res.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		g.record(Exchange{
+			Request: ExchangeRequest{
+				Path: req.URL.Path,
+				Body: compactJSON(reqBody),
+			},
+			Response: ExchangeResponse{
+				Status: res.StatusCode,
+				Body:   compactJSON(resBody),
+			},
+		})
+
+		return res, nil
+	})
+}
+
+type golden struct {
+	path string
+
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+func (g *golden) record(e Exchange) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.exchanges = append(g.exchanges, e)
+}
+
+func (g *golden) check(t testing.TB) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	got, err := json.MarshalIndent(g.exchanges, "", "\t")
+	if err != nil {
+		t.Fatalf("crpctest: cannot encode exchanges: %s", err)
+	}
+
+	got = append(got, '\n')
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(g.path), 0o755); err != nil {
+			t.Fatalf("crpctest: cannot create golden file: %s", err)
+		}
+
+		if err := os.WriteFile(g.path, got, 0o644); err != nil {
+			t.Fatalf("crpctest: cannot write golden file: %s", err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(g.path)
+	if err != nil {
+		t.Fatalf("crpctest: cannot read golden file, run with -crpctest.update to create it: %s", err)
+	}
+
+	if !bytes.Equal(want, got) {
+		t.Errorf("crpctest: exchanges differ from %s, run with -crpctest.update to rewrite it\n\nwant:\n%s\ngot:\n%s", g.path, want, got)
+	}
+}
+
+// readBody reads and replaces body, so it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := (*body).Close(); err != nil {
+		return nil, err
+	}
+
+	*body = io.NopCloser(bytes.NewReader(b))
+
+	return b, nil
+}
+
+// compactJSON returns b as compacted JSON, or as a JSON string if it is not
+// valid JSON, such as a streamed response.
+func compactJSON(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err == nil {
+		return buf.Bytes()
+	}
+
+	s, _ := json.Marshal(string(b))
+	return s
+}
@@ -0,0 +1,73 @@
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+)
+
+// RequestTimeoutHeader is the request header carrying the time remaining, in
+// whole milliseconds, before the caller gives up on the request.
+const RequestTimeoutHeader = "Request-Timeout"
+
+// SetTimeout sets the longest a registered method and version may run for.
+// The deadline sent by the caller in the Request-Timeout header is capped to
+// it, and it applies to requests which do not send one. Later versions which
+// inherit the method share its timeout, until it is registered again. If the
+// method has not been registered, SetTimeout will panic.
+func (s *Server) SetTimeout(method, version string, timeout time.Duration) {
+	s.mustGetHandler(method, version).timeout = timeout
+}
+
+// requestTimeout returns how long the request may run for, from the caller's
+// Request-Timeout header capped to max. It returns false if neither apply.
+func requestTimeout(req *Request, max time.Duration) (time.Duration, bool, error) {
+	value := req.GetHeader(RequestTimeoutHeader)
+	if value == "" {
+		return max, max > 0, nil
+	}
+
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, cher.New(cher.BadRequest, nil, cher.New("invalid_request_timeout", cher.M{"value": value}))
+	}
+
+	// the budget was spent before the request arrived, so the caller has
+	// already given up
+	if ms <= 0 {
+		return 0, false, cher.New(cher.RequestTimeout, nil)
+	}
+
+	timeout := time.Duration(ms) * time.Millisecond
+	if max > 0 && max < timeout {
+		timeout = max
+	}
+
+	return timeout, true, nil
+}
+
+// withRequestTimeout returns a request modifier setting the Request-Timeout
+// header from the deadline of ctx, if it has one. A nil ctx, which the client
+// accepts, has no deadline.
+func withRequestTimeout(ctx context.Context) func(r *http.Request) {
+	return func(r *http.Request) {
+		if ctx == nil {
+			return
+		}
+
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return
+		}
+
+		ms := time.Until(deadline).Milliseconds()
+		if ms < 0 {
+			ms = 0
+		}
+
+		r.Header.Set(RequestTimeoutHeader, strconv.FormatInt(ms, 10))
+	}
+}
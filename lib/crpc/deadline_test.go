@@ -0,0 +1,121 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	var remaining time.Duration
+	var hasDeadline bool
+
+	handler := func(ctx context.Context) error {
+		var deadline time.Time
+		deadline, hasDeadline = ctx.Deadline()
+		remaining = time.Until(deadline)
+
+		return nil
+	}
+
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.Register("quote", "2019-01-01", nil, handler)
+	zs.Register("ping", "2019-01-01", nil, handler)
+	zs.Register("pong", "2019-02-01", nil, handler)
+
+	zs.SetTimeout("quote", "2019-01-01", 100*time.Millisecond)
+
+	tests := []struct {
+		Name           string
+		Path           string
+		RequestTimeout string
+		Status         int
+		Deadline       bool
+		Max            time.Duration
+	}{
+		{"NoDeadline", "/2019-01-01/ping", "", http.StatusNoContent, false, 0},
+		{"Propagated", "/2019-01-01/ping", "5000", http.StatusNoContent, true, 5 * time.Second},
+		{"ServerTimeout", "/2019-01-01/quote", "", http.StatusNoContent, true, 100 * time.Millisecond},
+		{"Capped", "/2019-01-01/quote", "5000", http.StatusNoContent, true, 100 * time.Millisecond},
+		{"Shorter", "/2019-01-01/quote", "50", http.StatusNoContent, true, 50 * time.Millisecond},
+		{"Inherited", "/2019-02-01/quote", "5000", http.StatusNoContent, true, 100 * time.Millisecond},
+		{"Exhausted", "/2019-01-01/ping", "0", http.StatusInternalServerError, false, 0},
+		{"Invalid", "/2019-01-01/ping", "soon", http.StatusBadRequest, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			remaining, hasDeadline = 0, false
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", test.Path, nil)
+			if test.RequestTimeout != "" {
+				r.Header.Set(RequestTimeoutHeader, test.RequestTimeout)
+			}
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.Deadline, hasDeadline)
+
+			if test.Deadline {
+				assert.LessOrEqual(t, remaining, test.Max)
+				assert.Greater(t, remaining, test.Max-50*time.Millisecond)
+			}
+		})
+	}
+
+	t.Run("ExhaustedError", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/ping", nil)
+		r.Header.Set(RequestTimeoutHeader, "0")
+
+		zs.ServeHTTP(w, r)
+
+		assert.JSONEq(t, `{"code":"request_timeout"}`, w.Body.String())
+	})
+}
+
+func TestClientRequestTimeout(t *testing.T) {
+	var header string
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(RequestTimeoutHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hs.Close()
+
+	c := NewClient(context.Background(), hs.URL+"/", nil)
+
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		require.NoError(t, c.Do(ctx, "ping", "2019-01-01", nil, nil))
+
+		ms, err := strconv.ParseInt(header, 10, 64)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, ms, int64(5000))
+		assert.Greater(t, ms, int64(4000))
+	})
+
+	t.Run("NoDeadline", func(t *testing.T) {
+		require.NoError(t, c.Do(context.Background(), "ping", "2019-01-01", nil, nil))
+
+		assert.Empty(t, header)
+	})
+
+	t.Run("NilContext", func(t *testing.T) {
+		//nolint:staticcheck // a nil context was accepted before deadlines were propagated
+		require.NoError(t, c.Do(nil, "ping", "2019-01-01", nil, nil))
+
+		assert.Empty(t, header)
+	})
+}
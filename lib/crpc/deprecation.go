@@ -0,0 +1,79 @@
+package crpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DeprecationHeader is the response header indicating when a method was
+	// or will be deprecated, as defined by RFC 9745.
+	DeprecationHeader = "Deprecation"
+
+	// SunsetHeader is the response header indicating when a method will stop
+	// responding, as defined by RFC 8594.
+	SunsetHeader = "Sunset"
+)
+
+// Deprecation describes the withdrawal of a method version.
+type Deprecation struct {
+	// Date is when the method was or will be deprecated.
+	Date time.Time `json:"date"`
+
+	// Sunset is when the method will stop responding, after which calls are
+	// answered with no_longer_supported. If zero, the method has no sunset
+	// date and continues to respond.
+	Sunset time.Time `json:"sunset,omitempty"`
+
+	// Link is an optional URL documenting the deprecation, such as a
+	// migration guide.
+	Link string `json:"link,omitempty"`
+}
+
+// Deprecate marks a registered method and version as deprecated. Responses
+// include the Deprecation, Sunset and Link headers, and after the sunset
+// date calls are answered with no_longer_supported. Later versions which
+// inherit the method are also deprecated, until it is registered again. If
+// the method has not been registered, Deprecate will panic.
+func (s *Server) Deprecate(method, version string, d Deprecation) {
+	s.mustGetHandler(method, version).deprecation = &d
+}
+
+// mustGetHandler returns the handler registered for a method and version,
+// panicking if it has not been registered.
+func (s *Server) mustGetHandler(method, version string) *handler {
+	var hn *handler
+
+	if version == VersionPreview {
+		hn = s.registeredPreviewMethods[method]
+	} else {
+		hn = s.registeredVersionMethods[version][method]
+	}
+
+	if hn == nil {
+		panic(fmt.Sprintf("'%s' on version '%s' is not defined", method, version))
+	}
+
+	return hn
+}
+
+// sunset reports whether the method is past its sunset date.
+func (d *Deprecation) sunset(now time.Time) bool {
+	return !d.Sunset.IsZero() && !now.Before(d.Sunset)
+}
+
+// appendDeprecationHeaders applies the headers describing the deprecation
+// of the method version called.
+func appendDeprecationHeaders(w http.ResponseWriter, d *Deprecation) {
+	w.Header().Set(DeprecationHeader, "@"+strconv.FormatInt(d.Date.Unix(), 10))
+
+	if !d.Sunset.IsZero() {
+		w.Header().Set(SunsetHeader, d.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if d.Link != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, d.Link))
+	}
+}
@@ -0,0 +1,120 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecate(t *testing.T) {
+	date := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	reg := prometheus.NewRegistry()
+
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.Use(Instrument(reg))
+	zs.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			r.WithContext(context.WithValue(r.Context(), request.ClientVersionKey, &request.ClientVersion{
+				Platform: request.ClientPlatformIOS,
+				Version:  semver.MustParse("3.6.8"),
+			}))
+
+			return next(w, r)
+		}
+	})
+
+	handler := func(context.Context) error { return nil }
+
+	zs.Register("quote", "2019-01-01", nil, handler)
+	zs.Register("legacy", "2019-01-01", nil, handler)
+	zs.Register("quote", "2019-02-01", nil, handler)
+	zs.Register("ping", "2019-02-01", nil, handler)
+
+	zs.Deprecate("quote", "2019-01-01", Deprecation{Date: date, Sunset: sunset, Link: "https://docs.cuvva.com/quote"})
+	zs.Deprecate("legacy", "2019-01-01", Deprecation{Date: date, Sunset: date})
+
+	tests := []struct {
+		Name        string
+		Path        string
+		Status      int
+		Deprecation string
+		Sunset      string
+		Link        string
+	}{
+		{"Deprecated", "/2019-01-01/quote", http.StatusNoContent, "@1546300800", sunset.Format(http.TimeFormat), `<https://docs.cuvva.com/quote>; rel="deprecation"; type="text/html"`},
+		{"Replaced", "/2019-02-01/quote", http.StatusNoContent, "", "", ""},
+		{"Sunset", "/2019-01-01/legacy", http.StatusGone, "@1546300800", "Tue, 01 Jan 2019 00:00:00 GMT", ""},
+		{"Inherited", "/2019-02-01/legacy", http.StatusGone, "@1546300800", "Tue, 01 Jan 2019 00:00:00 GMT", ""},
+		{"NotDeprecated", "/2019-02-01/ping", http.StatusNoContent, "", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", test.Path, nil)
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.Deprecation, w.Header().Get(DeprecationHeader))
+			assert.Equal(t, test.Sunset, w.Header().Get(SunsetHeader))
+			assert.Equal(t, test.Link, w.Header().Get("Link"))
+			assert.NotEmpty(t, w.Header().Get(CuvvaEndpointStatus))
+		})
+	}
+
+	t.Run("Instrument", func(t *testing.T) {
+		mfs, err := reg.Gather()
+		assert.NoError(t, err)
+
+		var found bool
+
+		for _, mf := range mfs {
+			if mf.GetName() != "rpc_deprecated_request_total" {
+				continue
+			}
+
+			found = true
+
+			if !assert.Len(t, mf.GetMetric(), 1) {
+				continue
+			}
+
+			labels := map[string]string{}
+			for _, lp := range mf.GetMetric()[0].GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+
+			assert.Equal(t, map[string]string{"method": "quote", "version": "2019-01-01", "platform": "ios", "client_version": "3.6.8"}, labels)
+			assert.Equal(t, float64(1), mf.GetMetric()[0].GetCounter().GetValue())
+		}
+
+		assert.True(t, found)
+	})
+
+	t.Run("Catalogue", func(t *testing.T) {
+		mi := zs.Catalogue().Versions["2019-01-01"]
+		if assert.Len(t, mi, 2) {
+			assert.Equal(t, date, mi[1].Deprecation.Date)
+		}
+
+		op := zs.OpenAPI(OpenAPIInfo{}).Paths["/2019-01-01/quote"].Post
+		assert.True(t, op.Deprecated)
+	})
+
+	t.Run("Undefined", func(t *testing.T) {
+		assert.Panics(t, func() {
+			zs.Deprecate("delete", "2019-01-01", Deprecation{Date: date})
+		})
+	})
+}
@@ -0,0 +1,303 @@
+package crpc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// VersionDiff describes the methods which changed between two versions.
+type VersionDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Added are the methods available on To but not From.
+	Added []MethodInfo `json:"added,omitempty"`
+
+	// Removed are the methods available on From but not To.
+	Removed []MethodInfo `json:"removed,omitempty"`
+
+	// Changed are the methods available on both versions which were
+	// registered again after From.
+	Changed []MethodChange `json:"changed,omitempty"`
+}
+
+// MethodChange describes a method which was registered again between two
+// versions.
+type MethodChange struct {
+	Method string     `json:"method"`
+	From   MethodInfo `json:"from"`
+	To     MethodInfo `json:"to"`
+
+	// Incompatibilities are the changes which break clients of From.
+	Incompatibilities []Incompatibility `json:"incompatibilities,omitempty"`
+}
+
+// Incompatibility describes a change to a method which breaks existing
+// clients.
+type Incompatibility struct {
+	// Path locates the change within the request schema, such as
+	// "$.address.postcode", or is empty for changes to the method itself.
+	Path string `json:"path,omitempty"`
+
+	// Reason identifies the kind of change, such as "field_required".
+	Reason string `json:"reason"`
+}
+
+func (i Incompatibility) String() string {
+	if i.Path == "" {
+		return i.Reason
+	}
+
+	return fmt.Sprintf("%s: %s", i.Path, i.Reason)
+}
+
+// Breaking reports whether any method was removed, or changed in a way which
+// breaks existing clients.
+func (d *VersionDiff) Breaking() bool {
+	if len(d.Removed) > 0 {
+		return true
+	}
+
+	for _, mc := range d.Changed {
+		if len(mc.Incompatibilities) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff returns the methods added, removed and registered again between two
+// versions registered with the server. It returns an error if either version
+// is unknown.
+func (s *Server) Diff(from, to string) (*VersionDiff, error) {
+	return s.Catalogue().Diff(from, to)
+}
+
+// Diff returns the methods added, removed and registered again between two
+// versions of the catalogue, checking the request schemas of methods which
+// were registered again for backwards-incompatible changes. It returns an
+// error if either version is unknown.
+func (c *Catalogue) Diff(from, to string) (*VersionDiff, error) {
+	fromMethods, err := c.methods(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toMethods, err := c.methods(to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &VersionDiff{From: from, To: to}
+
+	for name, mi := range fromMethods {
+		if _, ok := toMethods[name]; !ok {
+			d.Removed = append(d.Removed, mi)
+		}
+	}
+
+	for name, mi := range toMethods {
+		prev, ok := fromMethods[name]
+		if !ok {
+			d.Added = append(d.Added, mi)
+			continue
+		}
+
+		if prev.Version != mi.Version {
+			d.Changed = append(d.Changed, MethodChange{
+				Method:            name,
+				From:              prev,
+				To:                mi,
+				Incompatibilities: compareMethods(prev, mi),
+			})
+		}
+	}
+
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Method < d.Added[j].Method })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Method < d.Removed[j].Method })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Method < d.Changed[j].Method })
+
+	return d, nil
+}
+
+func (c *Catalogue) methods(version string) (map[string]MethodInfo, error) {
+	if version == VersionLatest {
+		version = c.Latest
+	}
+
+	methods, ok := c.Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("crpc: version %q is not in the catalogue", version)
+	}
+
+	set := make(map[string]MethodInfo, len(methods))
+	for _, mi := range methods {
+		set[mi.Method] = mi
+	}
+
+	return set, nil
+}
+
+// compareMethods returns the changes to a method which break clients of the
+// earlier registration.
+func compareMethods(from, to MethodInfo) []Incompatibility {
+	var changes []Incompatibility
+
+	switch {
+	case from.AcceptsInput && !to.AcceptsInput:
+		changes = append(changes, Incompatibility{Reason: "input_removed"})
+	case !from.AcceptsInput && to.AcceptsInput:
+		changes = append(changes, Incompatibility{Reason: "input_required"})
+	case from.AcceptsInput && to.AcceptsInput:
+		changes = append(changes, compareSchemas("$", from.Schema, to.Schema)...)
+	}
+
+	if from.ReturnsResult != to.ReturnsResult {
+		changes = append(changes, Incompatibility{Reason: "result_changed"})
+	} else if from.Streams != to.Streams {
+		changes = append(changes, Incompatibility{Reason: "streaming_changed"})
+	}
+
+	return changes
+}
+
+// compareSchemas returns the changes to a request schema which would reject
+// requests accepted by the earlier schema: new required fields, removed
+// properties and narrowed types or enums.
+func compareSchemas(path string, from, to interface{}) []Incompatibility {
+	fromSchema, _ := from.(map[string]interface{})
+	toSchema, _ := to.(map[string]interface{})
+
+	if fromSchema == nil || toSchema == nil {
+		return nil
+	}
+
+	var changes []Incompatibility
+
+	if narrowsTypes(schemaTypes(fromSchema), schemaTypes(toSchema)) {
+		changes = append(changes, Incompatibility{Path: path, Reason: "type_narrowed"})
+	}
+
+	if narrowsEnum(fromSchema["enum"], toSchema["enum"]) {
+		changes = append(changes, Incompatibility{Path: path, Reason: "enum_narrowed"})
+	}
+
+	fromRequired := stringSet(fromSchema["required"])
+	for _, name := range sortedKeys(stringSet(toSchema["required"])) {
+		if !fromRequired[name] {
+			changes = append(changes, Incompatibility{Path: path + "." + name, Reason: "field_required"})
+		}
+	}
+
+	fromProperties, _ := fromSchema["properties"].(map[string]interface{})
+	toProperties, _ := toSchema["properties"].(map[string]interface{})
+
+	for _, name := range sortedKeys(fromProperties) {
+		toProperty, ok := toProperties[name]
+		if !ok {
+			changes = append(changes, Incompatibility{Path: path + "." + name, Reason: "property_removed"})
+			continue
+		}
+
+		changes = append(changes, compareSchemas(path+"."+name, fromProperties[name], toProperty)...)
+	}
+
+	changes = append(changes, compareSchemas(path+"[]", fromSchema["items"], toSchema["items"])...)
+
+	return changes
+}
+
+// schemaTypes returns the set of types allowed by a schema, or nil if any
+// type is allowed.
+func schemaTypes(schema map[string]interface{}) map[string]bool {
+	switch t := schema["type"].(type) {
+	case string:
+		return map[string]bool{t: true}
+	case []interface{}:
+		return stringSet(t)
+	}
+
+	return nil
+}
+
+// narrowsTypes reports whether a value of a type allowed by from may not be
+// allowed by to.
+func narrowsTypes(from, to map[string]bool) bool {
+	if to == nil {
+		return false
+	} else if from == nil {
+		return true
+	}
+
+	for t := range from {
+		// every integer is also a number
+		if !to[t] && !(t == "integer" && to["number"]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// narrowsEnum reports whether a value allowed by the from enum may not be
+// allowed by the to enum.
+func narrowsEnum(from, to interface{}) bool {
+	toValues, ok := to.([]interface{})
+	if !ok {
+		return false
+	}
+
+	fromValues, ok := from.([]interface{})
+	if !ok {
+		return true
+	}
+
+	for _, fv := range fromValues {
+		var found bool
+
+		for _, tv := range toValues {
+			if reflect.DeepEqual(fv, tv) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stringSet(v interface{}) map[string]bool {
+	set := map[string]bool{}
+
+	switch values := v.(type) {
+	case []interface{}:
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				set[s] = true
+			}
+		}
+	case []string:
+		for _, s := range values {
+			set[s] = true
+		}
+	}
+
+	return set
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
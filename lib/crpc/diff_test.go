@@ -0,0 +1,152 @@
+package crpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestDiff(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	handler := func(context.Context, *handleRequest) error { return nil }
+	empty := func(context.Context) error { return nil }
+
+	v1Schema := gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"required": [ "name" ],
+		"properties": {
+			"name": { "type": "string" },
+			"age": { "type": "integer" },
+			"cover": { "type": "string", "enum": [ "basic", "full" ] },
+			"address": {
+				"type": "object",
+				"properties": {
+					"postcode": { "type": [ "string", "null" ] }
+				}
+			}
+		}
+	}`)
+
+	v2Schema := gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"required": [ "name", "email" ],
+		"properties": {
+			"name": { "type": "string" },
+			"age": { "type": "number" },
+			"email": { "type": "string" },
+			"cover": { "type": "string", "enum": [ "full" ] },
+			"address": {
+				"type": "object",
+				"properties": {
+					"postcode": { "type": "string" }
+				}
+			}
+		}
+	}`)
+
+	zs.Register("quote", "2023-01-10", v1Schema, handler)
+	zs.Register("compatible", "2023-01-10", v1Schema, handler)
+	zs.Register("legacy", "2023-01-10", nil, empty)
+	zs.Register("ping", "2023-01-10", nil, empty)
+	zs.Register("quote", "2024-05-02", v2Schema, handler)
+	zs.Register("compatible", "2024-05-02", v1Schema, handler)
+	zs.Register("legacy", "2024-05-02", nil, nil)
+	zs.Register("ping", "2024-05-02", v1Schema, handler)
+	zs.Register("greet", "2024-05-02", nil, empty)
+
+	diff, err := zs.Diff("2023-01-10", VersionLatest)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2023-01-10", diff.From)
+	assert.Equal(t, VersionLatest, diff.To)
+	assert.True(t, diff.Breaking())
+
+	if assert.Len(t, diff.Added, 1) {
+		assert.Equal(t, "greet", diff.Added[0].Method)
+	}
+
+	if assert.Len(t, diff.Removed, 1) {
+		assert.Equal(t, "legacy", diff.Removed[0].Method)
+	}
+
+	changes := map[string][]Incompatibility{}
+	for _, mc := range diff.Changed {
+		changes[mc.Method] = mc.Incompatibilities
+	}
+
+	assert.Equal(t, map[string][]Incompatibility{
+		"compatible": nil,
+		"ping":       {{Reason: "input_required"}},
+		"quote": {
+			{Path: "$.email", Reason: "field_required"},
+			{Path: "$.address.postcode", Reason: "type_narrowed"},
+			{Path: "$.cover", Reason: "enum_narrowed"},
+		},
+	}, changes)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		diff, err := zs.Diff("2024-05-02", "2024-05-02")
+		require.NoError(t, err)
+
+		assert.Empty(t, diff.Added)
+		assert.Empty(t, diff.Removed)
+		assert.Empty(t, diff.Changed)
+		assert.False(t, diff.Breaking())
+	})
+
+	t.Run("UnknownVersion", func(t *testing.T) {
+		_, err := zs.Diff("2020-01-01", "2024-05-02")
+		assert.EqualError(t, err, `crpc: version "2020-01-01" is not in the catalogue`)
+	})
+
+	t.Run("Catalogue", func(t *testing.T) {
+		// a catalogue fetched from a server can be compared in the same way
+		b, err := json.Marshal(zs.Catalogue())
+		require.NoError(t, err)
+
+		var c Catalogue
+		require.NoError(t, json.Unmarshal(b, &c))
+
+		fetched, err := c.Diff("2023-01-10", "2024-05-02")
+		require.NoError(t, err)
+
+		assert.Equal(t, diff.Breaking(), fetched.Breaking())
+		assert.Len(t, fetched.Changed, len(diff.Changed))
+	})
+}
+
+func TestCompareSchemas(t *testing.T) {
+	tests := []struct {
+		Name    string
+		From    string
+		To      string
+		Changes []Incompatibility
+	}{
+		{"Identical", `{"type":"string"}`, `{"type":"string"}`, nil},
+		{"Widened", `{"type":"string"}`, `{"type":["string","null"]}`, nil},
+		{"IntegerToNumber", `{"type":"integer"}`, `{"type":"number"}`, nil},
+		{"NumberToInteger", `{"type":"number"}`, `{"type":"integer"}`, []Incompatibility{{Path: "$", Reason: "type_narrowed"}}},
+		{"TypeAdded", `{}`, `{"type":"string"}`, []Incompatibility{{Path: "$", Reason: "type_narrowed"}}},
+		{"EnumAdded", `{"type":"string"}`, `{"type":"string","enum":["a"]}`, []Incompatibility{{Path: "$", Reason: "enum_narrowed"}}},
+		{"EnumWidened", `{"enum":["a"]}`, `{"enum":["a","b"]}`, nil},
+		{"OptionalAdded", `{"properties":{}}`, `{"properties":{"a":{}}}`, nil},
+		{"RequiredRemoved", `{"required":["a"]}`, `{}`, nil},
+		{"PropertyRemoved", `{"properties":{"a":{}}}`, `{"properties":{}}`, []Incompatibility{{Path: "$.a", Reason: "property_removed"}}},
+		{"Items", `{"items":{"type":["string","integer"]}}`, `{"items":{"type":"string"}}`, []Incompatibility{{Path: "$[]", Reason: "type_narrowed"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var from, to interface{}
+			require.NoError(t, json.Unmarshal([]byte(test.From), &from))
+			require.NoError(t, json.Unmarshal([]byte(test.To), &to))
+
+			assert.Equal(t, test.Changes, compareSchemas("$", from, to))
+		})
+	}
+}
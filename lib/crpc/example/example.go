@@ -6,6 +6,8 @@ import (
 	"github.com/xeipuuv/gojsonschema"
 )
 
+//go:generate go run ../../../cmd/crpcgen generate github.com/cuvva/cuvva-public-go/lib/crpc/example --version 2017-11-08 --package exampleclient --output exampleclient/client_gen.go
+
 type Service interface {
 	Ping(context.Context) error
 	Greet(context.Context, *GreetRequest) (*GreetResponse, error)
@@ -0,0 +1,74 @@
+// Code generated by crpcgen. DO NOT EDIT.
+
+package exampleclient
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+	example "github.com/cuvva/cuvva-public-go/lib/crpc/example"
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient"
+)
+
+// Client is a typed crpc client for example.Service.
+type Client struct {
+	*crpc.Client
+}
+
+var _ example.Service = (*Client)(nil)
+
+// NewClient returns a client configured with a transport scheme, remote host
+// and URL prefix supplied as a URL <scheme>://<host></prefix>. Options, such
+// as jsonclient.WithInstrumentation, are passed on to crpc.NewClient.
+func NewClient(ctx context.Context, baseURL string, c *http.Client, opts ...jsonclient.Option) *Client {
+	return &Client{crpc.NewClient(ctx, baseURL, c, opts...)}
+}
+
+// Greet calls greet (version 2017-11-08).
+func (c *Client) Greet(ctx context.Context, req *example.GreetRequest) (*example.GreetResponse, error) {
+	var res *example.GreetResponse
+	err := c.Do(ctx, "greet", "2017-11-08", req, &res)
+	return res, err
+}
+
+// Ping calls ping (version 2017-11-08).
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Do(ctx, "ping", "2017-11-08", nil, nil)
+}
+
+// Fake is an implementation of example.Service for use in
+// tests. Each method calls the matching func field, or returns a
+// not_implemented error if it is nil.
+type Fake struct {
+	GreetFunc func(ctx context.Context, req *example.GreetRequest) (*example.GreetResponse, error)
+	PingFunc  func(ctx context.Context) error
+}
+
+var _ example.Service = (*Fake)(nil)
+
+// Greet calls GreetFunc.
+func (f *Fake) Greet(ctx context.Context, req *example.GreetRequest) (*example.GreetResponse, error) {
+	if f.GreetFunc == nil {
+		return nil, cher.New("not_implemented", cher.M{"method": "greet", "version": "2017-11-08"})
+	}
+
+	return f.GreetFunc(ctx, req)
+}
+
+// Ping calls PingFunc.
+func (f *Fake) Ping(ctx context.Context) error {
+	if f.PingFunc == nil {
+		return cher.New("not_implemented", cher.M{"method": "ping", "version": "2017-11-08"})
+	}
+
+	return f.PingFunc(ctx)
+}
+
+// Register associates each method of svc with s, using the same methods and
+// versions the Client is pinned to.
+func Register(s *crpc.Server, svc example.Service, mw ...crpc.MiddlewareFunc) {
+	s.Register("greet", "2017-11-08", example.GreetRequestSchema, svc.Greet, mw...)
+	s.Register("ping", "2017-11-08", nil, svc.Ping, mw...)
+}
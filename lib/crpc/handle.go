@@ -0,0 +1,129 @@
+package crpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Handle associates a method name and version with a handler accepting a
+// request and returning a response. Unlike Register, the handler signature is
+// checked at compile time and no reflection is used to serve requests.
+//
+// Req must be a struct and a schema must be given, otherwise Handle will
+// panic. This function is not thread safe and must be run in serial if called
+// multiple times.
+func Handle[Req, Res any](s *Server, method, version string, schema gojsonschema.JSONLoader, fn func(context.Context, *Req) (*Res, error), mw ...MiddlewareFunc) {
+	mustHandleRequest[Req](schema)
+	mustHandleResponse[Res]()
+
+	s.handle(method, version, schema, &WrappedFunc{
+		Handler: func(w http.ResponseWriter, r *Request) error {
+			req := new(Req)
+			if err := decodeRequestBody(r, req); err != nil {
+				return err
+			}
+
+			res, err := fn(r.Context(), req)
+			if err != nil {
+				return err
+			}
+
+			return encodeResponseBody(w, r, res)
+		},
+		AcceptsInput:  true,
+		ReturnsResult: true,
+	}, mw...)
+}
+
+// HandleNoResponse is the same as Handle, for handlers which accept a
+// request but do not return a response. Requests are answered with
+// `204 No Content`.
+func HandleNoResponse[Req any](s *Server, method, version string, schema gojsonschema.JSONLoader, fn func(context.Context, *Req) error, mw ...MiddlewareFunc) {
+	mustHandleRequest[Req](schema)
+
+	s.handle(method, version, schema, &WrappedFunc{
+		Handler: func(w http.ResponseWriter, r *Request) error {
+			req := new(Req)
+			if err := decodeRequestBody(r, req); err != nil {
+				return err
+			}
+
+			if err := fn(r.Context(), req); err != nil {
+				return err
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		},
+		AcceptsInput: true,
+	}, mw...)
+}
+
+// HandleNoRequest is the same as Handle, for handlers which do not accept a
+// request. Requests with a body are rejected.
+func HandleNoRequest[Res any](s *Server, method, version string, fn func(context.Context) (*Res, error), mw ...MiddlewareFunc) {
+	mustHandleResponse[Res]()
+
+	s.handle(method, version, nil, &WrappedFunc{
+		Handler: func(w http.ResponseWriter, r *Request) error {
+			if err := expectNoRequestBody(r); err != nil {
+				return err
+			}
+
+			res, err := fn(r.Context())
+			if err != nil {
+				return err
+			}
+
+			return encodeResponseBody(w, r, res)
+		},
+		ReturnsResult: true,
+	}, mw...)
+}
+
+// HandleEmpty is the same as Handle, for handlers which neither accept a
+// request nor return a response.
+func HandleEmpty(s *Server, method, version string, fn func(context.Context) error, mw ...MiddlewareFunc) {
+	s.handle(method, version, nil, &WrappedFunc{
+		Handler: func(w http.ResponseWriter, r *Request) error {
+			if err := expectNoRequestBody(r); err != nil {
+				return err
+			}
+
+			if err := fn(r.Context()); err != nil {
+				return err
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		},
+	}, mw...)
+}
+
+func (s *Server) handle(method, version string, schema gojsonschema.JSONLoader, wrapped *WrappedFunc, mw ...MiddlewareFunc) {
+	s.register(method, version, schema, &wrapped.Handler, wrapped, mw...)
+}
+
+// mustHandleRequest panics if Req is not a struct or no schema is given,
+// matching the checks made by Wrap and Register.
+func mustHandleRequest[Req any](schema gojsonschema.JSONLoader) {
+	if t := reflect.TypeOf((*Req)(nil)).Elem(); t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("fn last argument must be a struct, got %s", t.Kind()))
+	}
+
+	if schema == nil {
+		panic("no schema validation configured")
+	}
+}
+
+// mustHandleResponse panics if *Res is not a supported return type, matching
+// the checks made by Wrap.
+func mustHandleResponse[Res any]() {
+	if _, err := wrapReturn(reflect.TypeOf((*Res)(nil))); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,122 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/stretchr/testify/assert"
+)
+
+type handleRequest struct {
+	Name string `json:"name"`
+}
+
+func TestHandle(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	Handle(zs, "greet", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		return &testResponse{Message: "hello " + req.Name}, nil
+	})
+	HandleNoResponse(zs, "update", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) error {
+		if req.Name == "fail" {
+			return cher.New("update_failed", nil)
+		}
+
+		return nil
+	})
+	HandleNoRequest(zs, "get", "2019-01-01", func(context.Context) (*testResponse, error) {
+		return &testResponse{Message: "got"}, nil
+	})
+	HandleEmpty(zs, "ping", "2019-01-01", func(context.Context) error {
+		return nil
+	})
+
+	tests := []struct {
+		Name   string
+		Path   string
+		Body   string
+		Status int
+		Result string
+	}{
+		{"Handle", "/2019-01-01/greet", `{"name": "james"}`, http.StatusOK, "{\"message\":\"hello james\"}\n"},
+		{"HandleSchemaFailure", "/2019-01-01/greet", `{}`, http.StatusBadRequest, ""},
+		{"HandleNoResponse", "/2019-01-01/update", `{"name": "james"}`, http.StatusNoContent, ""},
+		{"HandleNoResponseError", "/2019-01-01/update", `{"name": "fail"}`, http.StatusBadRequest, "{\"code\":\"update_failed\"}\n"},
+		{"HandleNoRequest", "/2019-01-01/get", ``, http.StatusOK, "{\"message\":\"got\"}\n"},
+		{"HandleNoRequestUnexpectedBody", "/2019-01-01/get", `{}`, http.StatusBadRequest, ""},
+		{"HandleEmpty", "/latest/ping", ``, http.StatusNoContent, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", test.Path, strings.NewReader(test.Body))
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+
+			if test.Result != "" {
+				assert.Equal(t, test.Result, w.Body.String())
+			}
+		})
+	}
+
+	mi := zs.Catalogue().Versions["2019-01-01"]
+	if assert.Len(t, mi, 4) {
+		assert.Equal(t, MethodInfo{Method: "get", Version: "2019-01-01", ReturnsResult: true}, mi[0])
+		assert.True(t, mi[1].AcceptsInput)
+		assert.Equal(t, MethodInfo{Method: "ping", Version: "2019-01-01"}, mi[2])
+		assert.False(t, mi[3].ReturnsResult)
+	}
+}
+
+func TestHandlePanics(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	assert.PanicsWithValue(t, "no schema validation configured", func() {
+		HandleNoResponse(zs, "update", "2019-01-01", nil, func(context.Context, *handleRequest) error { return nil })
+	})
+
+	assert.PanicsWithValue(t, "fn last argument must be a struct, got string", func() {
+		HandleNoResponse(zs, "update", "2019-01-01", catalogueSchema, func(context.Context, *string) error { return nil })
+	})
+
+	assert.Panics(t, func() {
+		HandleNoRequest(zs, "get", "2019-01-01", func(context.Context) (*string, error) { return nil, nil })
+	})
+}
+
+func BenchmarkHandle(b *testing.B) {
+	handler := func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		return &testResponse{Message: req.Name}, nil
+	}
+
+	b.Run("Register", func(b *testing.B) {
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Register("greet", "2019-01-01", catalogueSchema, handler)
+
+		benchmarkServe(b, zs)
+	})
+
+	b.Run("Handle", func(b *testing.B) {
+		zs := NewServer(UnsafeNoAuthentication)
+		Handle(zs, "greet", "2019-01-01", catalogueSchema, handler)
+
+		benchmarkServe(b, zs)
+	})
+}
+
+func benchmarkServe(b *testing.B, zs *Server) {
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/greet", strings.NewReader(`{"name": "james"}`))
+
+		zs.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,388 @@
+package crpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/go-redis/redis"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header containing the client
+	// generated key identifying retries of the same request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses which have been replayed
+	// from an earlier request with the same idempotency key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyLockDuration is the default longest a request is
+	// expected to be in progress.
+	DefaultIdempotencyLockDuration = time.Minute
+
+	// DefaultIdempotencyResponseDuration is the default time responses are
+	// recorded for.
+	DefaultIdempotencyResponseDuration = 24 * time.Hour
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotentResponse is the response recorded against an idempotency key,
+// either a successful response or the error returned by the handler, along
+// with a hash of the request body it was made in response to.
+type IdempotentResponse struct {
+	RequestHash string `json:"request_hash"`
+
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Error  *cher.E     `json:"error,omitempty"`
+}
+
+// IdempotencyStore records responses against idempotency keys.
+type IdempotencyStore interface {
+	// Begin reserves key for a request in progress, returning true if the
+	// reservation was made. Otherwise, the recorded response is returned,
+	// or nil if another request with the same key is still in progress.
+	Begin(ctx context.Context, key string) (res *IdempotentResponse, ok bool, err error)
+
+	// Complete records the response to the request which reserved key. If
+	// the reservation has since expired or been taken by another request,
+	// the response is not recorded.
+	Complete(ctx context.Context, key string, res *IdempotentResponse) error
+
+	// Release removes the reservation of key without recording a response,
+	// allowing the request to be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// Idempotency records the response of requests with an Idempotency-Key header
+// and replays it to any retries of the request, so retried requests are not
+// executed twice. A retry made while the original request is still in
+// progress, or reusing a key with a different request body, is rejected with a
+// conflict error.
+//
+// Keys are scoped to the authenticated principal, method and version, so
+// Idempotency must be given to Register to run after authentication. Requests
+// with a key but no principal are rejected with unauthorized, as their keys
+// could not be kept apart from those of other callers.
+//
+// Successful responses and errors the client is expected to handle are
+// recorded. Server errors are not, so the request can be retried.
+func Idempotency(store IdempotencyStore) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res http.ResponseWriter, req *Request) error {
+			key := req.GetHeader(IdempotencyKeyHeader)
+			if key == "" {
+				return next(res, req)
+			} else if len(key) > maxIdempotencyKeyLength {
+				return cher.New(cher.BadRequest, nil, cher.New("invalid_idempotency_key", cher.M{"max_length": maxIdempotencyKeyLength}))
+			}
+
+			ctx := req.Context()
+
+			p := GetPrincipal(ctx)
+			if p == nil {
+				return cher.New(cher.Unauthorized, nil)
+			}
+
+			key = strings.Join([]string{p.Subject(), req.Method, req.Version, key}, "/")
+
+			hash, err := hashRequestBody(req)
+			if err != nil {
+				return err
+			}
+
+			recorded, ok, err := store.Begin(ctx, key)
+			if err != nil {
+				return fmt.Errorf("crpc: idempotency store failed: %w", err)
+			}
+
+			if recorded != nil {
+				if recorded.RequestHash != hash {
+					return cher.New(cher.Conflict, nil, cher.New("idempotency_key_reused", nil))
+				}
+
+				return replayIdempotentResponse(res, recorded)
+			} else if !ok {
+				return cher.New(cher.Conflict, nil, cher.New("idempotent_request_in_progress", nil))
+			}
+
+			// a panicking request is never completed, so release the key to
+			// allow it to be retried
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					_ = store.Release(ctx, key)
+					panic(recovered)
+				}
+			}()
+
+			// the handler writes its headers to a map of its own, so only
+			// they are recorded, and not those set by outer middleware
+			buf := newResponseBuffer(nil)
+
+			handlerErr := next(buf, req)
+
+			for key, values := range buf.Header() {
+				res.Header()[key] = values
+			}
+
+			recorded = &IdempotentResponse{
+				RequestHash: hash,
+				Status:      buf.Status(),
+				Header:      buf.Header().Clone(),
+				Body:        buf.body.Bytes(),
+			}
+
+			if handlerErr != nil {
+				cErr, isCher := cher.Translate(handlerErr)
+				if !isCher || cErr.StatusCode() >= http.StatusInternalServerError {
+					if err := store.Release(ctx, key); err != nil {
+						return fmt.Errorf("crpc: idempotency store failed: %w", err)
+					}
+
+					return handlerErr
+				}
+
+				recorded.Status = cErr.StatusCode()
+				recorded.Body = nil
+				recorded.Error = &cErr
+			}
+
+			if err := store.Complete(ctx, key, recorded); err != nil {
+				return fmt.Errorf("crpc: idempotency store failed: %w", err)
+			}
+
+			if handlerErr != nil {
+				return handlerErr
+			}
+
+			return buf.flush(res)
+		}
+	}
+}
+
+// hashRequestBody reads the request body to hash it, replacing it with a copy
+// for the handler to read.
+func hashRequestBody(req *Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, recorded *IdempotentResponse) error {
+	for key, values := range recorded.Header {
+		w.Header()[key] = values
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+
+	if recorded.Error != nil {
+		return *recorded.Error
+	}
+
+	w.WriteHeader(recorded.Status)
+
+	_, err := w.Write(recorded.Body)
+	return err
+}
+
+// RedisIdempotencyStore implements a Redis-backed IdempotencyStore.
+type RedisIdempotencyStore struct {
+	// Redis is the storage backend for recorded responses.
+	Redis redis.Cmdable
+
+	// RedisPrefix will prefix all keys used by the store.
+	RedisPrefix string
+
+	// LockDuration is the longest a request is expected to be in progress,
+	// after which its reservation expires. Defaults to
+	// DefaultIdempotencyLockDuration if zero.
+	LockDuration time.Duration
+
+	// ResponseDuration is how long responses are recorded for. Defaults to
+	// DefaultIdempotencyResponseDuration if zero.
+	ResponseDuration time.Duration
+}
+
+func (s *RedisIdempotencyStore) lockDuration() time.Duration {
+	if s.LockDuration > 0 {
+		return s.LockDuration
+	}
+
+	return DefaultIdempotencyLockDuration
+}
+
+func (s *RedisIdempotencyStore) responseDuration() time.Duration {
+	if s.ResponseDuration > 0 {
+		return s.ResponseDuration
+	}
+
+	return DefaultIdempotencyResponseDuration
+}
+
+// inProgress is stored against a key while the request is in progress.
+const inProgress = "in_progress"
+
+// completeScript records a response against a key only while it is still
+// reserved, so a request whose reservation expired can't overwrite the
+// response recorded by another.
+var completeScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+
+return 1
+`)
+
+// releaseScript removes a key only while it is still reserved, leaving any
+// recorded response in place.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+
+return redis.call("DEL", KEYS[1])
+`)
+
+func (s *RedisIdempotencyStore) key(key string) string {
+	if s.RedisPrefix != "" {
+		return s.RedisPrefix + "/" + key
+	}
+
+	return key
+}
+
+// Begin reserves key for a request in progress, or returns the recorded
+// response.
+func (s *RedisIdempotencyStore) Begin(_ context.Context, key string) (*IdempotentResponse, bool, error) {
+	key = s.key(key)
+
+	ok, err := s.Redis.SetNX(key, inProgress, s.lockDuration()).Result()
+	if err != nil {
+		return nil, false, err
+	} else if ok {
+		return nil, true, nil
+	}
+
+	value, err := s.Redis.Get(key).Result()
+	if err == redis.Nil || value == inProgress {
+		// if the key expired between commands, treat as still in progress
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var res IdempotentResponse
+	if err := json.Unmarshal([]byte(value), &res); err != nil {
+		return nil, false, err
+	}
+
+	return &res, false, nil
+}
+
+// Complete records the response to the request which reserved key.
+func (s *RedisIdempotencyStore) Complete(_ context.Context, key string, res *IdempotentResponse) error {
+	value, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	return completeScript.Run(s.Redis, []string{s.key(key)}, inProgress, value, s.responseDuration().Milliseconds()).Err()
+}
+
+// Release removes the reservation of key.
+func (s *RedisIdempotencyStore) Release(_ context.Context, key string) error {
+	return releaseScript.Run(s.Redis, []string{s.key(key)}, inProgress).Err()
+}
+
+// MemoryIdempotencyStore implements an in-memory IdempotencyStore, intended
+// for tests. The zero value is ready to use, and never expires responses.
+type MemoryIdempotencyStore struct {
+	// ResponseDuration is how long responses are recorded for. If zero,
+	// responses are recorded forever.
+	ResponseDuration time.Duration
+
+	mu      sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+}
+
+type memoryIdempotencyEntry struct {
+	res     *IdempotentResponse
+	expires time.Time
+}
+
+// Begin reserves key for a request in progress, or returns the recorded
+// response.
+func (s *MemoryIdempotencyStore) Begin(_ context.Context, key string) (*IdempotentResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = make(map[string]memoryIdempotencyEntry)
+	}
+
+	entry, ok := s.entries[key]
+	if ok && (entry.expires.IsZero() || time.Now().Before(entry.expires)) {
+		return entry.res, false, nil
+	}
+
+	s.entries[key] = memoryIdempotencyEntry{}
+
+	return nil, true, nil
+}
+
+// Complete records the response to the request which reserved key.
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, res *IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; !ok || entry.res != nil {
+		return nil
+	}
+
+	entry := memoryIdempotencyEntry{res: res}
+	if s.ResponseDuration > 0 {
+		entry.expires = time.Now().Add(s.ResponseDuration)
+	}
+
+	s.entries[key] = entry
+
+	return nil
+}
+
+// Release removes the reservation of key.
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.res == nil {
+		delete(s.entries, key)
+	}
+
+	return nil
+}
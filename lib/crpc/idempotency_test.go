@@ -0,0 +1,237 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPrincipal string
+
+func (p testPrincipal) Subject() string { return string(p) }
+
+func TestIdempotency(t *testing.T) {
+	var calls int
+
+	store := &MemoryIdempotencyStore{}
+
+	zs := NewServer(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			if sub := r.GetHeader("X-Subject"); sub != "" {
+				SetPrincipal(r, testPrincipal(sub))
+			}
+
+			return next(w, r)
+		}
+	})
+
+	var outer int
+
+	zs.Use(Recover(prometheus.NewRegistry(), nil))
+	zs.Use(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			outer++
+			w.Header().Set("X-Outer", strconv.Itoa(outer))
+
+			return next(w, r)
+		}
+	})
+
+	zs.Register("pay", "2019-01-01", catalogueSchema, func(_ context.Context, req *handleRequest) (*testResponse, error) {
+		calls++
+
+		switch req.Name {
+		case "panic":
+			panic("boom")
+		case "declined":
+			return nil, cher.New("payment_declined", nil)
+		case "broken":
+			return nil, cher.New(cher.Unknown, nil)
+		}
+
+		return &testResponse{Message: "paid " + req.Name}, nil
+	}, Idempotency(store))
+
+	call := func(subject, key, name string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/pay", strings.NewReader(`{"name": "`+name+`"}`))
+		r.Header.Set("X-Subject", subject)
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+
+		zs.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("without key", func(t *testing.T) {
+		calls = 0
+
+		call("user_1", "", "james")
+		call("user_1", "", "james")
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("replays response", func(t *testing.T) {
+		calls = 0
+
+		first := call("user_1", "key_1", "james")
+		second := call("user_1", "key_1", "james")
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+
+		// headers set by outer middleware are not recorded and replayed
+		assert.NotEqual(t, first.Header().Get("X-Outer"), second.Header().Get("X-Outer"))
+
+		recorded, _, err := store.Begin(context.Background(), "user_1/pay/2019-01-01/key_1")
+		require.NoError(t, err)
+		require.NotNil(t, recorded)
+		assert.NotContains(t, recorded.Header, "X-Outer")
+	})
+
+	t.Run("scoped to subject", func(t *testing.T) {
+		calls = 0
+
+		call("user_1", "key_2", "james")
+		call("user_2", "key_2", "james")
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("replays client error", func(t *testing.T) {
+		calls = 0
+
+		call("user_1", "key_3", "declined")
+		w := call("user_1", "key_3", "declined")
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "{\"code\":\"payment_declined\"}\n", w.Body.String())
+	})
+
+	t.Run("retries server error", func(t *testing.T) {
+		calls = 0
+
+		call("user_1", "key_4", "broken")
+		w := call("user_1", "key_4", "broken")
+
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("rejects in progress", func(t *testing.T) {
+		calls = 0
+
+		_, ok, err := store.Begin(context.Background(), "user_1/pay/2019-01-01/key_5")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		w := call("user_1", "key_5", "james")
+
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "idempotent_request_in_progress")
+	})
+
+	t.Run("releases after panic", func(t *testing.T) {
+		calls = 0
+
+		call("user_1", "key_6", "panic")
+		w := call("user_1", "key_6", "panic")
+
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "idempotent_request_in_progress")
+	})
+
+	t.Run("rejects reused key", func(t *testing.T) {
+		calls = 0
+
+		call("user_1", "key_7", "james")
+		w := call("user_1", "key_7", "jane")
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "idempotency_key_reused")
+	})
+
+	t.Run("rejects without principal", func(t *testing.T) {
+		calls = 0
+
+		w := call("", "key_8", "james")
+
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("rejects long key", func(t *testing.T) {
+		w := call("user_1", strings.Repeat("a", 256), "james")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_idempotency_key")
+	})
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryIdempotencyStore{}
+
+	t.Run("completes own reservation", func(t *testing.T) {
+		_, ok, err := store.Begin(ctx, "key_1")
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.NoError(t, store.Complete(ctx, "key_1", &IdempotentResponse{Status: http.StatusOK}))
+
+		recorded, _, err := store.Begin(ctx, "key_1")
+		require.NoError(t, err)
+		require.NotNil(t, recorded)
+		assert.Equal(t, http.StatusOK, recorded.Status)
+	})
+
+	t.Run("does not overwrite recorded response", func(t *testing.T) {
+		require.NoError(t, store.Complete(ctx, "key_1", &IdempotentResponse{Status: http.StatusCreated}))
+		require.NoError(t, store.Release(ctx, "key_1"))
+
+		recorded, _, err := store.Begin(ctx, "key_1")
+		require.NoError(t, err)
+		require.NotNil(t, recorded)
+		assert.Equal(t, http.StatusOK, recorded.Status)
+	})
+
+	t.Run("does not record without reservation", func(t *testing.T) {
+		require.NoError(t, store.Complete(ctx, "key_2", &IdempotentResponse{Status: http.StatusOK}))
+
+		recorded, ok, err := store.Begin(ctx, "key_2")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, recorded)
+	})
+}
+
+func TestRedisIdempotencyStoreDefaults(t *testing.T) {
+	s := &RedisIdempotencyStore{}
+	assert.Equal(t, DefaultIdempotencyLockDuration, s.lockDuration())
+	assert.Equal(t, DefaultIdempotencyResponseDuration, s.responseDuration())
+
+	s = &RedisIdempotencyStore{LockDuration: time.Second, ResponseDuration: time.Hour}
+	assert.Equal(t, time.Second, s.lockDuration())
+	assert.Equal(t, time.Hour, s.responseDuration())
+}
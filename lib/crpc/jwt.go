@@ -0,0 +1,542 @@
+package crpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/config"
+)
+
+const (
+	jwtRS256 = "RS256"
+	jwtES256 = "ES256"
+
+	// DefaultJWTClockSkew is the default leeway allowed when checking the
+	// expiry and not before times of a token.
+	DefaultJWTClockSkew = 30 * time.Second
+
+	// DefaultTokenTTL is the default lifetime of tokens signed by a
+	// TokenSigner.
+	DefaultTokenTTL = 5 * time.Minute
+)
+
+var jwtEncoding = base64.RawURLEncoding
+
+// Claims are the verified claims of a JSON Web Token. Claims implements
+// Principal, with the subject of the token as the caller.
+type Claims struct {
+	claims jwtClaims
+	raw    map[string]json.RawMessage
+}
+
+type jwtClaims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  jwtAudience `json:"aud,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+	Scope     string      `json:"scope,omitempty"`
+	Roles     []string    `json:"roles,omitempty"`
+}
+
+// jwtAudience is encoded as a string when it has a single value, and as an
+// array otherwise.
+type jwtAudience []string
+
+func (a jwtAudience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = jwtAudience{single}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// GetClaims returns the claims of the token used to authenticate a request
+// from its context, or nil if the request was not authenticated by
+// JWTAuthentication.
+func GetClaims(ctx context.Context) *Claims {
+	c, _ := GetPrincipal(ctx).(*Claims)
+	return c
+}
+
+// Subject returns the subject (sub) of the token.
+func (c *Claims) Subject() string { return c.claims.Subject }
+
+// Issuer returns the issuer (iss) of the token.
+func (c *Claims) Issuer() string { return c.claims.Issuer }
+
+// Audience returns the audience (aud) of the token.
+func (c *Claims) Audience() []string { return c.claims.Audience }
+
+// ID returns the unique identifier (jti) of the token.
+func (c *Claims) ID() string { return c.claims.ID }
+
+// ExpiresAt returns the expiry time (exp) of the token.
+func (c *Claims) ExpiresAt() time.Time { return time.Unix(c.claims.ExpiresAt, 0) }
+
+// IssuedAt returns the time the token was issued (iat), or the zero time if
+// not set.
+func (c *Claims) IssuedAt() time.Time {
+	if c.claims.IssuedAt == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(c.claims.IssuedAt, 0)
+}
+
+// Scopes returns the space separated scopes (scope) granted to the token.
+func (c *Claims) Scopes() []string { return strings.Fields(c.claims.Scope) }
+
+// Roles returns the roles (roles) granted to the token.
+func (c *Claims) Roles() []string { return c.claims.Roles }
+
+// Get decodes the named claim into dst, returning false if the claim is not
+// present in the token.
+func (c *Claims) Get(name string, dst interface{}) (bool, error) {
+	raw, ok := c.raw[name]
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(raw, dst)
+}
+
+// JWTOption configures the verification of tokens by JWTAuthentication.
+type JWTOption func(*jwtVerifier)
+
+// JWTClockSkew sets the leeway allowed when checking the expiry and not
+// before times of a token, defaulting to DefaultJWTClockSkew.
+func JWTClockSkew(skew time.Duration) JWTOption {
+	return func(v *jwtVerifier) {
+		v.skew = skew
+	}
+}
+
+type jwtVerifier struct {
+	key      crypto.PublicKey
+	alg      string
+	issuer   string
+	audience []string
+	skew     time.Duration
+}
+
+// JWTAuthentication returns middleware for use as the AuthenticationMiddleware
+// of a Server, verifying RS256 or ES256 signed bearer tokens against the public key and issuer in cfg. The
+// algorithm is determined by the type of the public key.
+//
+// Tokens must be intended for at least one of the given audiences. Both the
+// issuer and the audience are required, so tokens signed for another service
+// with the same key are not accepted.
+//
+// The verified Claims are set as the principal of the request, and are
+// returned by GetClaims. Missing, invalid and expired tokens, and tokens from
+// another issuer, are rejected with unauthorized. Tokens for other audiences
+// are rejected with access_denied.
+func JWTAuthentication(cfg config.JWT, audience []string, opts ...JWTOption) (MiddlewareFunc, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("crpc: jwt issuer is required")
+	}
+
+	if len(audience) == 0 {
+		return nil, errors.New("crpc: jwt audience is required")
+	}
+
+	key, err := parsePublicKey(cfg.Public)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := jwtAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &jwtVerifier{
+		key:      key,
+		alg:      alg,
+		issuer:   cfg.Issuer,
+		audience: audience,
+		skew:     DefaultJWTClockSkew,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			token, ok := bearerToken(r.GetHeader("Authorization"))
+			if !ok {
+				return cher.New(cher.Unauthorized, nil, cher.New("missing_token", nil))
+			}
+
+			claims, err := v.verify(token, time.Now())
+			if err != nil {
+				return err
+			}
+
+			SetPrincipal(r, claims)
+
+			return next(w, r)
+		}
+	}, nil
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "bearer "
+
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+func (v *jwtVerifier) verify(token string, now time.Time) (*Claims, error) {
+	invalid := cher.New(cher.Unauthorized, nil, cher.New("invalid_token", nil))
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+	}
+
+	if err := decodeJWTSegment(parts[0], &header); err != nil || header.Algorithm != v.alg {
+		return nil, invalid
+	}
+
+	sig, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid
+	}
+
+	if !verifyJWTSignature(v.key, parts[0]+"."+parts[1], sig) {
+		return nil, invalid
+	}
+
+	c := &Claims{}
+	if err := decodeJWTSegment(parts[1], &c.claims); err != nil {
+		return nil, invalid
+	}
+
+	if err := decodeJWTSegment(parts[1], &c.raw); err != nil {
+		return nil, invalid
+	}
+
+	if c.claims.Issuer != v.issuer {
+		return nil, cher.New(cher.Unauthorized, nil, cher.New("invalid_issuer", nil))
+	}
+
+	if c.claims.ExpiresAt == 0 || now.Add(-v.skew).Unix() >= c.claims.ExpiresAt {
+		return nil, cher.New(cher.Unauthorized, nil, cher.New("token_expired", nil))
+	}
+
+	if c.claims.NotBefore != 0 && now.Add(v.skew).Unix() < c.claims.NotBefore {
+		return nil, cher.New(cher.Unauthorized, nil, cher.New("token_not_yet_valid", nil))
+	}
+
+	if !intersects(v.audience, c.claims.Audience) {
+		return nil, cher.New(cher.AccessDenied, nil, cher.New("invalid_audience", nil))
+	}
+
+	return c, nil
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func decodeJWTSegment(segment string, dst interface{}) error {
+	b, err := jwtEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, dst)
+}
+
+func verifyJWTSignature(key crypto.PublicKey, input string, sig []byte) bool {
+	digest := sha256.Sum256([]byte(input))
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+
+		return ecdsa.Verify(k, digest[:], r, s)
+	}
+
+	return false
+}
+
+func jwtAlgorithm(key crypto.PublicKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwtRS256, nil
+
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return jwtES256, nil
+		}
+	}
+
+	return "", fmt.Errorf("crpc: unsupported jwt key type %T", key)
+}
+
+func parsePublicKey(pemKey string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("crpc: jwt public key is not pem encoded")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func parsePrivateKey(pemKey string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("crpc: jwt private key is not pem encoded")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("crpc: unsupported jwt key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// TokenClaims are the claims of a token signed by a TokenSigner.
+type TokenClaims struct {
+	Subject  string
+	Audience []string
+	Scopes   []string
+	Roles    []string
+}
+
+// TokenSigner signs RS256 or ES256 tokens for service-to-service requests,
+// using the private key and issuer in a config.JWT.
+type TokenSigner struct {
+	// TTL is the lifetime of signed tokens.
+	TTL time.Duration
+
+	key    crypto.Signer
+	alg    string
+	issuer string
+}
+
+// NewTokenSigner returns a TokenSigner for the private key and issuer in
+// cfg. The algorithm is determined by the type of the private key.
+func NewTokenSigner(cfg config.JWT) (*TokenSigner, error) {
+	key, err := parsePrivateKey(cfg.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := jwtAlgorithm(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenSigner{
+		TTL: DefaultTokenTTL,
+
+		key:    key,
+		alg:    alg,
+		issuer: cfg.Issuer,
+	}, nil
+}
+
+// Sign returns a token with the given claims, valid from now for the TTL of
+// the signer.
+func (s *TokenSigner) Sign(tc TokenClaims) (string, error) {
+	return s.sign(tc, time.Now())
+}
+
+func (s *TokenSigner) sign(tc TokenClaims, now time.Time) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": s.alg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(jwtClaims{
+		Issuer:    s.issuer,
+		Subject:   tc.Subject,
+		Audience:  tc.Audience,
+		ExpiresAt: now.Add(s.TTL).Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        hex.EncodeToString(id),
+		Scope:     strings.Join(tc.Scopes, " "),
+		Roles:     tc.Roles,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	input := jwtEncoding.EncodeToString(header) + "." + jwtEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+
+	switch k := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		r, rs, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return "", err
+		}
+
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		rs.FillBytes(sig[32:])
+
+	default:
+		sig, err = s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return input + "." + jwtEncoding.EncodeToString(sig), nil
+}
+
+// ServiceTokenRoundTripper attaches a bearer token signed by a TokenSigner to
+// requests before handing them to the embedded transport for execution.
+// Tokens are reused until half of their lifetime has passed.
+type ServiceTokenRoundTripper struct {
+	http.RoundTripper
+
+	signer *TokenSigner
+	claims TokenClaims
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+}
+
+// NewServiceTokenRoundTripper returns a new ServiceTokenRoundTripper that
+// will sign tokens with the given claims.
+func NewServiceTokenRoundTripper(rt http.RoundTripper, signer *TokenSigner, claims TokenClaims) *ServiceTokenRoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	return &ServiceTokenRoundTripper{
+		RoundTripper: rt,
+
+		signer: signer,
+		claims: claims,
+	}
+}
+
+// RoundTrip applies authentication before performing the request.
+func (st *ServiceTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := st.getToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return st.RoundTripper.RoundTrip(req)
+}
+
+func (st *ServiceTokenRoundTripper) getToken() (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	if st.token != "" && now.Before(st.refresh) {
+		return st.token, nil
+	}
+
+	token, err := st.signer.sign(st.claims, now)
+	if err != nil {
+		return "", err
+	}
+
+	st.token = token
+	st.refresh = now.Add(st.signer.TTL / 2)
+
+	return token, nil
+}
+
+// WithServiceToken attaches a bearer token signed by signer to every request
+// made by the client.
+func (c *Client) WithServiceToken(signer *TokenSigner, claims TokenClaims) *Client {
+	hc := *c.Client.Client
+	hc.Transport = NewServiceTokenRoundTripper(hc.Transport, signer, claims)
+	c.Client.Client = &hc
+
+	return c
+}
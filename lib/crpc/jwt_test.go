@@ -0,0 +1,186 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeJWTConfig(t *testing.T, issuer string, key interface{ Public() crypto.PublicKey }) config.JWT {
+	t.Helper()
+
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	return config.JWT{
+		Issuer:  issuer,
+		Public:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+		Private: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private})),
+	}
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaCfg := makeJWTConfig(t, "auth", rsaKey)
+	ecCfg := makeJWTConfig(t, "auth", ecKey)
+	otherCfg := makeJWTConfig(t, "other", ecKey)
+
+	rsaSigner, err := NewTokenSigner(rsaCfg)
+	require.NoError(t, err)
+
+	ecSigner, err := NewTokenSigner(ecCfg)
+	require.NoError(t, err)
+
+	otherSigner, err := NewTokenSigner(otherCfg)
+	require.NoError(t, err)
+
+	claims := TokenClaims{
+		Subject:  "service_1",
+		Audience: []string{"service-quote"},
+		Scopes:   []string{"quotes:read", "quotes:write"},
+		Roles:    []string{"service"},
+	}
+
+	sign := func(signer *TokenSigner, tc TokenClaims, now time.Time) string {
+		token, err := signer.sign(tc, now)
+		require.NoError(t, err)
+		return token
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		Name  string
+		Cfg   config.JWT
+		Token string
+		Code  string
+	}{
+		{"RS256", rsaCfg, sign(rsaSigner, claims, now), ""},
+		{"ES256", ecCfg, sign(ecSigner, claims, now), ""},
+		{"WithinClockSkew", ecCfg, sign(ecSigner, claims, now.Add(-DefaultTokenTTL-10*time.Second)), ""},
+		{"Expired", ecCfg, sign(ecSigner, claims, now.Add(-DefaultTokenTTL-time.Minute)), cher.Unauthorized},
+		{"AlgorithmMismatch", ecCfg, sign(rsaSigner, claims, now), cher.Unauthorized},
+		{"InvalidIssuer", ecCfg, sign(otherSigner, claims, now), cher.Unauthorized},
+		{"InvalidAudience", ecCfg, sign(ecSigner, TokenClaims{Subject: "service_1", Audience: []string{"service-other"}}, now), cher.AccessDenied},
+		{"MissingAudience", ecCfg, sign(ecSigner, TokenClaims{Subject: "service_1"}, now), cher.AccessDenied},
+		{"Tampered", ecCfg, sign(ecSigner, claims, now) + "a", cher.Unauthorized},
+		{"Malformed", ecCfg, "not.a.token", cher.Unauthorized},
+		{"Missing", ecCfg, "", cher.Unauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			auth, err := JWTAuthentication(test.Cfg, []string{"service-quote"})
+			require.NoError(t, err)
+
+			var got *Claims
+
+			handler := auth(func(w http.ResponseWriter, r *Request) error {
+				got = GetClaims(r.Context())
+				return nil
+			})
+
+			r := &Request{Header: http.Header{}}
+			if test.Token != "" {
+				r.Header.Set("Authorization", "Bearer "+test.Token)
+			}
+
+			err = handler(httptest.NewRecorder(), r)
+
+			if test.Code != "" {
+				if assert.IsType(t, cher.E{}, err) {
+					assert.Equal(t, test.Code, err.(cher.E).Code)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, "service_1", got.Subject())
+			assert.Equal(t, "auth", got.Issuer())
+			assert.Equal(t, []string{"service-quote"}, got.Audience())
+			assert.Equal(t, []string{"quotes:read", "quotes:write"}, got.Scopes())
+			assert.Equal(t, []string{"service"}, got.Roles())
+			assert.NotEmpty(t, got.ID())
+			assert.Equal(t, "service_1", GetPrincipal(r.Context()).Subject())
+		})
+	}
+}
+
+func TestJWTAuthenticationInvalidKey(t *testing.T) {
+	_, err := JWTAuthentication(config.JWT{Issuer: "auth", Public: "not a key"}, []string{"service-quote"})
+	assert.Error(t, err)
+
+	_, err = NewTokenSigner(config.JWT{Private: "not a key"})
+	assert.Error(t, err)
+}
+
+func TestJWTAuthenticationRequiresIssuerAndAudience(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = JWTAuthentication(makeJWTConfig(t, "", key), []string{"service-quote"})
+	assert.EqualError(t, err, "crpc: jwt issuer is required")
+
+	_, err = JWTAuthentication(makeJWTConfig(t, "auth", key), nil)
+	assert.EqualError(t, err, "crpc: jwt audience is required")
+}
+
+func TestClientWithServiceToken(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	cfg := makeJWTConfig(t, "auth", key)
+
+	auth, err := JWTAuthentication(cfg, []string{"service-quote"})
+	require.NoError(t, err)
+
+	signer, err := NewTokenSigner(cfg)
+	require.NoError(t, err)
+
+	zs := NewServer(auth)
+	zs.Register("whoami", "2019-01-01", nil, func(ctx context.Context) (*testResponse, error) {
+		return &testResponse{Message: GetClaims(ctx).Subject()}, nil
+	})
+
+	hs := httptest.NewServer(zs)
+	defer hs.Close()
+
+	hc := &http.Client{}
+
+	c := NewClient(context.Background(), hs.URL+"/", hc).WithServiceToken(signer, TokenClaims{Subject: "service_1", Audience: []string{"service-quote"}})
+
+	var res testResponse
+	err = c.Do(context.Background(), "whoami", "2019-01-01", nil, &res)
+	require.NoError(t, err)
+	assert.Equal(t, "service_1", res.Message)
+	assert.Nil(t, hc.Transport, "original http client should not be modified")
+
+	err = NewClient(context.Background(), hs.URL+"/", nil).Do(context.Background(), "whoami", "2019-01-01", nil, &res)
+	if assert.IsType(t, cher.E{}, err) {
+		assert.Equal(t, cher.Unauthorized, err.(cher.E).Code)
+	}
+}
@@ -3,9 +3,11 @@ package crpc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"strings"
@@ -14,7 +16,9 @@ import (
 	"github.com/blang/semver"
 	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/cuvva/cuvva-public-go/lib/clog"
+	"github.com/cuvva/cuvva-public-go/lib/ksuid"
 	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
+	"github.com/cuvva/cuvva-public-go/lib/servicecontext"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/xeipuuv/gojsonschema"
@@ -81,7 +85,19 @@ func Validate(ls *gojsonschema.Schema) MiddlewareFunc {
 				return fmt.Errorf("crpc failed to read request body: %w", err)
 			}
 
-			ld := gojsonschema.NewBytesLoader(body)
+			// validate a JSON view of bodies encoded with other codecs
+			c := requestCodec(req)
+
+			view, err := jsonView(c, body)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return cher.New(cher.BadRequest, nil, cher.New("missing_request_body", nil))
+				}
+
+				return invalidBody(c, err)
+			}
+
+			ld := gojsonschema.NewBytesLoader(view)
 
 			result, err := ls.Validate(ld)
 			if err != nil {
@@ -103,6 +119,117 @@ func Validate(ls *gojsonschema.Schema) MiddlewareFunc {
 	}
 }
 
+// ValidateResponse buffers the JSON response and applies a JSON Schema
+// validation, to catch handlers returning something other than what clients
+// have been promised.
+//
+// Outside of production, every response is validated and a response failing
+// validation is replaced with an error. In production, only sampleRate (0-1)
+// of responses are validated and failures are logged, leaving the response
+// untouched. Services without a servicecontext are treated as production.
+//
+// Streamed responses are validated record by record, but are buffered in full
+// when validated.
+func ValidateResponse(schema gojsonschema.JSONLoader, sampleRate float64) MiddlewareFunc {
+	ls, err := gojsonschema.NewSchemaLoader().Compile(schema)
+	if err != nil {
+		panic(fmt.Sprintf("json schema error in response: %s", err))
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(res http.ResponseWriter, req *Request) error {
+			ctx := req.Context()
+
+			production := true
+			if svc := servicecontext.GetContext(ctx); svc != nil {
+				production = svc.Environment == ksuid.Production
+			}
+
+			if production && rand.Float64() >= sampleRate {
+				return next(res, req)
+			}
+
+			buf := newResponseBuffer(res.Header())
+
+			if err := next(buf, req); err != nil {
+				buf.flush(res)
+				return err
+			}
+
+			if buf.Status() == http.StatusOK {
+				if err := validateResponseBody(ls, buf); err != nil {
+					if !production {
+						// discard the buffered response, which may have been a stream
+						res.Header().Set("Content-Type", "application/json; charset=utf-8")
+						return *err
+					}
+
+					clog.Get(ctx).WithError(err).WithField("reasons", err.Reasons).Warn("response failed schema validation")
+				}
+			}
+
+			return buf.flush(res)
+		}
+	}
+}
+
+// validateResponseBody validates a buffered response body, or each record of
+// a streamed response.
+func validateResponseBody(ls *gojsonschema.Schema, buf *responseBuffer) *cher.E {
+	body := buf.body.Bytes()
+
+	// validate a JSON view of responses encoded with other codecs
+	if c := codecFor(buf.Header().Get("Content-Type")); c != nil && c != JSONCodec {
+		view, err := jsonView(c, body)
+		if err != nil {
+			return &cher.E{Code: cher.Unknown, Meta: cher.M{"message": "response could not be decoded"}}
+		}
+
+		body = view
+	}
+
+	streams := buf.Header().Get("Content-Type") == StreamContentType
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	for {
+		var record json.RawMessage
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return &cher.E{Code: cher.Unknown, Meta: cher.M{"message": "response is not valid JSON"}}
+		}
+
+		// validate the result enveloped by each record of a stream
+		if streams {
+			var rec struct {
+				Result json.RawMessage `json:"result"`
+			}
+
+			if err := json.Unmarshal(record, &rec); err != nil || rec.Result == nil {
+				continue
+			}
+
+			record = rec.Result
+		}
+
+		result, err := ls.Validate(gojsonschema.NewBytesLoader(record))
+		if err != nil {
+			return &cher.E{Code: cher.Unknown, Meta: cher.M{"message": err.Error()}}
+		}
+
+		if err := CoerceJSONSchemaError(result); err != nil {
+			reasons := err.(cher.E).Reasons
+
+			return &cher.E{
+				Code:    cher.Unknown,
+				Meta:    cher.M{"message": "response failed schema validation"},
+				Reasons: reasons,
+			}
+		}
+	}
+}
+
 func CoerceJSONSchemaError(result *gojsonschema.Result) error {
 	if result.Valid() {
 		return nil
@@ -130,6 +257,7 @@ func CoerceJSONSchemaError(result *gojsonschema.Result) error {
 //   - duration
 //   - status code
 //   - total in-flight
+//   - calls to deprecated methods, by client version
 func Instrument(r prometheus.Registerer) func(HandlerFunc) HandlerFunc {
 	reqDuration := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -154,9 +282,18 @@ func Instrument(r prometheus.Registerer) func(HandlerFunc) HandlerFunc {
 		[]string{"method", "version", "code"},
 	)
 
+	deprecatedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rpc_deprecated_request_total",
+			Help: "Total number of RPC requests to deprecated methods",
+		},
+		[]string{"method", "version", "platform", "client_version"},
+	)
+
 	r.MustRegister(reqDuration)
 	r.MustRegister(reqTotal)
 	r.MustRegister(resErrorCode)
+	r.MustRegister(deprecatedTotal)
 
 	return func(next HandlerFunc) HandlerFunc {
 		return func(w http.ResponseWriter, r *Request) error {
@@ -166,7 +303,7 @@ func Instrument(r prometheus.Registerer) func(HandlerFunc) HandlerFunc {
 
 			reqDuration.WithLabelValues(r.Method, r.Version).Observe(time.Since(start).Seconds())
 
-			if cuvvaErr, ok := err.(cher.E); ok {
+			if cuvvaErr, ok := cher.Translate(err); ok {
 				resErrorCode.WithLabelValues(r.Method, r.Version, cuvvaErr.Code).Inc()
 			} else if err != nil {
 				resErrorCode.WithLabelValues(r.Method, r.Version, "unknown").Inc()
@@ -174,6 +311,15 @@ func Instrument(r prometheus.Registerer) func(HandlerFunc) HandlerFunc {
 
 			reqTotal.WithLabelValues(r.Method, r.Version).Inc()
 
+			if r.deprecation != nil {
+				platform, clientVersion := "unknown", "unknown"
+				if cv := request.GetClientVersionContext(r.Context()); cv != nil {
+					platform, clientVersion = cv.Platform, cv.Version.String()
+				}
+
+				deprecatedTotal.WithLabelValues(r.Method, r.Version, platform, clientVersion).Inc()
+			}
+
 			return err
 		}
 	}
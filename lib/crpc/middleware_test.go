@@ -8,8 +8,11 @@ import (
 
 	"github.com/blang/semver"
 	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/ksuid"
 	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
+	"github.com/cuvva/cuvva-public-go/lib/servicecontext"
 	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 func TestRequireMinimumClientVersions(t *testing.T) {
@@ -146,3 +149,93 @@ func makeClientVersionRequest(ver *request.ClientVersion) *Request {
 		ctx: context.WithValue(context.Background(), request.ClientVersionKey, ver),
 	}
 }
+
+func TestValidateResponse(t *testing.T) {
+	schema := gojsonschema.NewStringLoader(`{
+		"type": "object",
+		"required": [ "message" ],
+		"properties": {
+			"message": { "type": "string", "minLength": 1 }
+		}
+	}`)
+
+	handler := func(msg string) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			return encodeResponseBody(w, r, testResponse{Message: msg})
+		}
+	}
+
+	makeRequest := func(env string) *Request {
+		ctx := context.Background()
+		if env != "" {
+			ctx = servicecontext.SetContext(ctx, "test", env)
+		}
+
+		return &Request{ctx: ctx}
+	}
+
+	t.Run("valid response is written", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		err := ValidateResponse(schema, 0)(handler("hello"))(w, makeRequest("local"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "{\"message\":\"hello\"}\n", w.Body.String())
+	})
+
+	t.Run("invalid response is replaced with an error outside production", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		err := ValidateResponse(schema, 0)(handler(""))(w, makeRequest("local"))
+		if assert.IsType(t, cher.E{}, err) {
+			assert.Equal(t, cher.Unknown, err.(cher.E).Code)
+			assert.Len(t, err.(cher.E).Reasons, 1)
+		}
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("invalid response is written in production", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		err := ValidateResponse(schema, 1)(handler(""))(w, makeRequest(ksuid.Production))
+		assert.NoError(t, err)
+		assert.Equal(t, "{\"message\":\"\"}\n", w.Body.String())
+	})
+
+	t.Run("invalid response is written without a servicecontext", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		err := ValidateResponse(schema, 1)(handler(""))(w, makeRequest(""))
+		assert.NoError(t, err)
+		assert.Equal(t, "{\"message\":\"\"}\n", w.Body.String())
+	})
+
+	t.Run("streamed records are validated", func(t *testing.T) {
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Use(func(next HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *Request) error {
+				r.WithContext(servicecontext.SetContext(r.Context(), "test", "local"))
+				return next(w, r)
+			}
+		})
+		zs.Register("stream", "preview", nil, streamIterHandler(2, nil), ValidateResponse(schema, 0))
+		zs.Register("invalid_stream", "preview", nil, func(context.Context) (func(yield func(*testResponse, error) bool), error) {
+			return func(yield func(*testResponse, error) bool) {
+				_ = yield(&testResponse{Message: "iter"}, nil) && yield(&testResponse{}, nil)
+			}, nil
+		}, ValidateResponse(schema, 0))
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/preview/stream", nil)
+		zs.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "{\"result\":{\"message\":\"iter\"}}\n{\"result\":{\"message\":\"iter\"}}\n", w.Body.String())
+
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("POST", "/preview/invalid_stream", nil)
+		zs.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
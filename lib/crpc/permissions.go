@@ -0,0 +1,105 @@
+package crpc
+
+import (
+	"net/http"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Permissions are the scopes and roles a caller must be granted to call a
+// method.
+type Permissions struct {
+	// Scopes must all be granted to the caller.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Roles are alternatives, at least one of which must be granted to the
+	// caller.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// AuthorizedPrincipal is a Principal which has been granted scopes and
+// roles, such as the Claims of a JSON Web Token.
+type AuthorizedPrincipal interface {
+	Principal
+
+	Scopes() []string
+	Roles() []string
+}
+
+// RequirePermissions requires callers of a registered method and version to
+// be granted the given permissions, checked against the principal set by the
+// AuthenticationMiddleware before any other middleware given to Register.
+// If the method has not been registered, RequirePermissions will panic.
+//
+// Callers without an AuthorizedPrincipal are rejected with unauthorized, and
+// callers missing any permission are rejected with access_denied.
+func (s *Server) RequirePermissions(method, version string, p Permissions) {
+	s.mustGetHandler(method, version).permissions = &p
+}
+
+// RegisterWithPermissions registers a method as Register does, requiring
+// callers to be granted the given permissions from the moment it is
+// registered, so the method is never served without them.
+func (s *Server) RegisterWithPermissions(method, version string, p Permissions, schema gojsonschema.JSONLoader, fnR interface{}, mw ...MiddlewareFunc) {
+	s.Register(method, version, schema, fnR, mw...)
+	s.RequirePermissions(method, version, p)
+}
+
+func (hn *handler) authorize(next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *Request) error {
+		if hn.permissions == nil {
+			return next(w, r)
+		}
+
+		p, ok := GetPrincipal(r.Context()).(AuthorizedPrincipal)
+		if !ok {
+			return cher.New(cher.Unauthorized, nil)
+		}
+
+		if err := hn.permissions.check(p); err != nil {
+			return err
+		}
+
+		return next(w, r)
+	}
+}
+
+func (p *Permissions) check(ap AuthorizedPrincipal) error {
+	missingScopes := difference(p.Scopes, ap.Scopes())
+	hasRole := len(p.Roles) == 0 || intersects(p.Roles, ap.Roles())
+
+	if len(missingScopes) == 0 && hasRole {
+		return nil
+	}
+
+	meta := cher.M{}
+
+	if len(missingScopes) > 0 {
+		meta["missing_scopes"] = missingScopes
+	}
+
+	if !hasRole {
+		meta["required_roles"] = p.Roles
+	}
+
+	return cher.New(cher.AccessDenied, meta)
+}
+
+// difference returns the values of a which are not in b.
+func difference(a, b []string) []string {
+	var diff []string
+
+outer:
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				continue outer
+			}
+		}
+
+		diff = append(diff, x)
+	}
+
+	return diff
+}
@@ -0,0 +1,94 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAuthorizedPrincipal struct {
+	scopes []string
+	roles  []string
+}
+
+func (p testAuthorizedPrincipal) Subject() string  { return "user_1" }
+func (p testAuthorizedPrincipal) Scopes() []string { return p.scopes }
+func (p testAuthorizedPrincipal) Roles() []string  { return p.roles }
+
+func TestRequirePermissions(t *testing.T) {
+	zs := NewServer(func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			if h := r.GetHeader("X-Scopes"); h != "" {
+				SetPrincipal(r, testAuthorizedPrincipal{
+					scopes: strings.Fields(h),
+					roles:  strings.Fields(r.GetHeader("X-Roles")),
+				})
+			}
+
+			return next(w, r)
+		}
+	})
+
+	handler := func(context.Context) error { return nil }
+
+	zs.Register("read", "2019-01-01", nil, handler)
+	zs.Register("write", "2019-01-01", nil, handler)
+	zs.RegisterWithPermissions("admin", "2019-01-01", Permissions{Roles: []string{"admin", "support"}}, nil, handler)
+
+	zs.RequirePermissions("write", "2019-01-01", Permissions{Scopes: []string{"quotes:read", "quotes:write"}})
+
+	tests := []struct {
+		Name   string
+		Method string
+		Scopes string
+		Roles  string
+		Status int
+		Body   string
+	}{
+		{"NoPermissions", "read", "", "", http.StatusNoContent, ""},
+		{"Unauthenticated", "write", "", "", http.StatusUnauthorized, "{\"code\":\"unauthorized\"}\n"},
+		{"AllScopes", "write", "quotes:write quotes:read", "", http.StatusNoContent, ""},
+		{"MissingScope", "write", "quotes:read", "", http.StatusForbidden, "{\"code\":\"access_denied\",\"meta\":{\"missing_scopes\":[\"quotes:write\"]}}\n"},
+		{"AnyRole", "admin", "none", "support", http.StatusNoContent, ""},
+		{"MissingRole", "admin", "none", "user", http.StatusForbidden, "{\"code\":\"access_denied\",\"meta\":{\"required_roles\":[\"admin\",\"support\"]}}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/2019-01-01/"+test.Method, nil)
+			r.Header.Set("X-Scopes", test.Scopes)
+			r.Header.Set("X-Roles", test.Roles)
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+
+			if test.Body != "" {
+				assert.Equal(t, test.Body, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Catalogue", func(t *testing.T) {
+		b, err := json.Marshal(zs.Catalogue().Versions["2019-01-01"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"method": "admin", "version": "2019-01-01", "accepts_input": false, "returns_result": false, "permissions": {"roles": ["admin", "support"]}},
+			{"method": "read", "version": "2019-01-01", "accepts_input": false, "returns_result": false},
+			{"method": "write", "version": "2019-01-01", "accepts_input": false, "returns_result": false, "permissions": {"scopes": ["quotes:read", "quotes:write"]}}
+		]`, string(b))
+	})
+
+	t.Run("Undefined", func(t *testing.T) {
+		assert.Panics(t, func() {
+			zs.RequirePermissions("delete", "2019-01-01", Permissions{})
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package crpc
+
+import (
+	"context"
+)
+
+// Principal is the authenticated caller of an RPC request.
+type Principal interface {
+	// Subject uniquely identifies the caller, such as a user or service ID.
+	Subject() string
+}
+
+const principalKey contextKey = "crpcprincipal"
+
+// SetPrincipal sets the authenticated caller of a request. It is intended to
+// be called by the AuthenticationMiddleware, so the principal is available to
+// any middleware given to Register and to the handler.
+//
+// Middleware configured with Server.Use runs before authentication, so will
+// not see the principal.
+func SetPrincipal(req *Request, p Principal) {
+	req.WithContext(context.WithValue(req.Context(), principalKey, p))
+}
+
+// GetPrincipal returns the authenticated caller of a request from its
+// context, or nil if the request has not been authenticated.
+func GetPrincipal(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey).(Principal); ok {
+		return p
+	}
+
+	return nil
+}
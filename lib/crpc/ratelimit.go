@@ -0,0 +1,121 @@
+package crpc
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/limiter"
+)
+
+// KeyFunc derives the token a request is rate limited against.
+type KeyFunc func(r *Request) string
+
+// KeyByRemoteAddr rate limits requests by the IP address of the caller.
+func KeyByRemoteAddr(r *Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+// KeyByPrincipal rate limits requests by the authenticated caller set with
+// SetPrincipal, or by the IP address of unauthenticated callers.
+func KeyByPrincipal(r *Request) string {
+	if p := GetPrincipal(r.Context()); p != nil {
+		return p.Subject()
+	}
+
+	return KeyByRemoteAddr(r)
+}
+
+// KeyByMethod rate limits requests by the version and method called.
+func KeyByMethod(r *Request) string {
+	return r.Version + "/" + r.Method
+}
+
+// KeyBy combines multiple KeyFuncs, rate limiting requests by all of them.
+func KeyBy(fns ...KeyFunc) KeyFunc {
+	return func(r *Request) string {
+		parts := make([]string, len(fns))
+		for i, fn := range fns {
+			parts[i] = fn(r)
+		}
+
+		return strings.Join(parts, "/")
+	}
+}
+
+// RateLimit rejects requests exceeding the rate allowed by l with a
+// too_many_requests error and a Retry-After header. If l implements
+// limiter.Policy, the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers are set on every response.
+//
+// The reset is the time left until the window of the caller resets, as
+// reported by l if it implements limiter.Resetter. Otherwise, windows are
+// taken to start at multiples of the window duration.
+//
+// To rate limit by the authenticated caller, RateLimit must be given to
+// Register to run after authentication.
+func RateLimit(l limiter.Limiter, key KeyFunc) MiddlewareFunc {
+	var limit int64
+	var window time.Duration
+
+	policy, hasPolicy := l.(limiter.Policy)
+	if hasPolicy {
+		limit, window = policy.Policy()
+	}
+
+	// allow reports whether the request can happen, and the time until the
+	// window of token resets
+	allow := func(token string) (int64, bool, time.Duration) {
+		if resetter, ok := l.(limiter.Resetter); ok {
+			return resetter.AllowReset(token)
+		}
+
+		count, ok := l.Allow(token)
+
+		var reset time.Duration
+		if window > 0 {
+			now := time.Now()
+			reset = now.Truncate(window).Add(window).Sub(now)
+		}
+
+		return count, ok, reset
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) error {
+			count, ok, reset := allow(key(r))
+
+			// round up so callers never retry early, and to at least one
+			resetSeconds := int64((reset + time.Second - 1) / time.Second)
+			if resetSeconds < 1 {
+				resetSeconds = 1
+			}
+
+			if hasPolicy {
+				remaining := limit - count - 1
+				if remaining < 0 || !ok {
+					remaining = 0
+				}
+
+				w.Header().Set("RateLimit-Limit", strconv.FormatInt(limit, 10))
+				w.Header().Set("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+				w.Header().Set("RateLimit-Reset", strconv.FormatInt(resetSeconds, 10))
+			}
+
+			if !ok {
+				w.Header().Set("Retry-After", strconv.FormatInt(resetSeconds, 10))
+
+				return cher.New(cher.TooManyRequests, nil)
+			}
+
+			return next(w, r)
+		}
+	}
+}
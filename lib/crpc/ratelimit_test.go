@@ -0,0 +1,135 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLimiter struct {
+	max    int64
+	counts map[string]int64
+}
+
+func (l *testLimiter) Allow(token string) (int64, bool) {
+	count := l.counts[token]
+	l.counts[token]++
+
+	return count, count < l.max
+}
+
+type testPolicyLimiter struct {
+	testLimiter
+}
+
+func (l *testPolicyLimiter) Policy() (int64, time.Duration) {
+	return l.max, time.Minute
+}
+
+type testResetLimiter struct {
+	testPolicyLimiter
+
+	reset time.Duration
+}
+
+func (l *testResetLimiter) AllowReset(token string) (int64, bool, time.Duration) {
+	count, ok := l.Allow(token)
+
+	return count, ok, l.reset
+}
+
+func TestRateLimit(t *testing.T) {
+	call := func(zs *Server, path, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", path, nil)
+		r.RemoteAddr = remoteAddr
+
+		zs.ServeHTTP(w, r)
+
+		return w
+	}
+
+	handler := func(context.Context) error { return nil }
+
+	t.Run("without policy", func(t *testing.T) {
+		l := &testLimiter{max: 1, counts: map[string]int64{}}
+
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Register("ping", "2019-01-01", nil, handler, RateLimit(l, KeyByRemoteAddr))
+
+		w := call(zs, "/2019-01-01/ping", "10.0.0.1:1234")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+		w = call(zs, "/2019-01-01/ping", "10.0.0.1:5678")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+		w = call(zs, "/2019-01-01/ping", "10.0.0.2:1234")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("with policy", func(t *testing.T) {
+		l := &testPolicyLimiter{testLimiter{max: 2, counts: map[string]int64{}}}
+
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Register("ping", "2019-01-01", nil, handler, RateLimit(l, KeyBy(KeyByPrincipal, KeyByMethod)))
+		zs.Register("pong", "2019-01-01", nil, handler, RateLimit(l, KeyBy(KeyByPrincipal, KeyByMethod)))
+
+		tests := []struct {
+			Path      string
+			Status    int
+			Remaining string
+		}{
+			{"/2019-01-01/ping", http.StatusNoContent, "1"},
+			{"/2019-01-01/ping", http.StatusNoContent, "0"},
+			{"/2019-01-01/ping", http.StatusTooManyRequests, "0"},
+			{"/2019-01-01/pong", http.StatusNoContent, "1"},
+		}
+
+		for _, test := range tests {
+			w := call(zs, test.Path, "10.0.0.1:1234")
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, test.Remaining, w.Header().Get("RateLimit-Remaining"))
+
+			// the time left in the current minute, not the whole window
+			reset, err := strconv.Atoi(w.Header().Get("RateLimit-Reset"))
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, reset, 1)
+			assert.LessOrEqual(t, reset, 60)
+
+			if test.Status == http.StatusTooManyRequests {
+				assert.Equal(t, w.Header().Get("RateLimit-Reset"), w.Header().Get("Retry-After"))
+			}
+		}
+
+		assert.Contains(t, l.counts, "10.0.0.1/2019-01-01/ping")
+	})
+
+	t.Run("with resetter", func(t *testing.T) {
+		l := &testResetLimiter{testPolicyLimiter{testLimiter{max: 1, counts: map[string]int64{}}}, 12500 * time.Millisecond}
+
+		zs := NewServer(UnsafeNoAuthentication)
+		zs.Register("ping", "2019-01-01", nil, handler, RateLimit(l, KeyByRemoteAddr))
+
+		w := call(zs, "/2019-01-01/ping", "10.0.0.1:1234")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "13", w.Header().Get("RateLimit-Reset"))
+
+		l.reset = 0
+
+		w = call(zs, "/2019-01-01/ping", "10.0.0.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+}
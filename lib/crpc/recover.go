@@ -0,0 +1,86 @@
+package crpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/clog"
+	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// ExceptionTracker reports recovered panics to an external service.
+type ExceptionTracker interface {
+	// CapturePanic reports a panic recovered while serving a request, with
+	// the value given to panic and the stack trace of the goroutine.
+	CapturePanic(ctx context.Context, r *Request, recovered interface{}, stack []byte)
+}
+
+// Recover turns a panic in a handler or middleware into an unknown error
+// carrying the request ID, instead of dropping the connection. The panic is
+// logged with its stack trace, counted in rpc_panics_total, and reported to
+// tracker if it is not nil.
+//
+// Recover should be the first middleware given to Use, so it wraps all
+// others.
+func Recover(r prometheus.Registerer, tracker ExceptionTracker) MiddlewareFunc {
+	panicTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rpc_panics_total",
+			Help: "Total number of panics recovered from RPC requests",
+		},
+		[]string{"method", "version"},
+	)
+
+	r.MustRegister(panicTotal)
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *Request) (err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				} else if recovered == http.ErrAbortHandler {
+					// deliberately aborted, so leave it to net/http
+					panic(recovered)
+				}
+
+				ctx := r.Context()
+
+				st := make([]byte, 1<<16)
+				st = st[:runtime.Stack(st, false)]
+
+				requestID := request.GetRequestIDContext(ctx)
+				if requestID == "" {
+					requestID = r.GetHeader("Request-Id")
+				}
+				if requestID == "" {
+					_, requestID = request.GetOrSetRequestID(ctx)
+				}
+
+				clog.Get(ctx).WithFields(logrus.Fields{
+					"error":       "panic",
+					"panic":       fmt.Sprint(recovered),
+					"stack_trace": string(st),
+					"rpc_method":  r.Method,
+					"rpc_version": r.Version,
+					"request_id":  requestID,
+				}).Error("rpc handler panicked")
+
+				panicTotal.WithLabelValues(r.Method, r.Version).Inc()
+
+				if tracker != nil {
+					tracker.CapturePanic(ctx, r, recovered, st)
+				}
+
+				err = cher.New(cher.Unknown, cher.M{"request_id": requestID})
+			}()
+
+			return next(w, r)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTracker struct {
+	method, version string
+	recovered       interface{}
+	stack           []byte
+}
+
+func (t *testTracker) CapturePanic(_ context.Context, r *Request, recovered interface{}, stack []byte) {
+	t.method, t.version = r.Method, r.Version
+	t.recovered, t.stack = recovered, stack
+}
+
+func TestRecover(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	tracker := &testTracker{}
+
+	zs := NewServer(UnsafeNoAuthentication)
+	zs.Use(Recover(reg, tracker))
+
+	zs.Register("explode", "2019-01-01", nil, func(context.Context) error {
+		panic("boom")
+	})
+	zs.Register("abort", "2019-01-01", nil, func(context.Context) error {
+		panic(http.ErrAbortHandler)
+	})
+	zs.Register("ping", "2019-01-01", nil, func(context.Context) error {
+		return nil
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/explode", nil)
+		r.Header.Set("Request-Id", "req_test")
+
+		zs.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"code":"unknown","meta":{"request_id":"req_test"}}`, w.Body.String())
+
+		assert.Equal(t, "explode", tracker.method)
+		assert.Equal(t, "2019-01-01", tracker.version)
+		assert.Equal(t, "boom", tracker.recovered)
+		assert.Contains(t, string(tracker.stack), "TestRecover")
+	})
+
+	t.Run("GeneratesRequestID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/explode", nil)
+
+		zs.ServeHTTP(w, r)
+
+		var e cher.E
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+
+		assert.Equal(t, cher.Unknown, e.Code)
+		assert.NotEmpty(t, e.Meta["request_id"])
+	})
+
+	t.Run("NoPanic", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/ping", nil)
+
+		zs.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/2019-01-01/abort", nil)
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			zs.ServeHTTP(w, r)
+		})
+	})
+
+	t.Run("Instrument", func(t *testing.T) {
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+
+		var found bool
+
+		for _, mf := range mfs {
+			if mf.GetName() != "rpc_panics_total" {
+				continue
+			}
+
+			found = true
+
+			if assert.Len(t, mf.GetMetric(), 1) {
+				assert.Equal(t, float64(2), mf.GetMetric()[0].GetCounter().GetValue())
+			}
+		}
+
+		assert.True(t, found)
+	})
+}
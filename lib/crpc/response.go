@@ -0,0 +1,63 @@
+package crpc
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// responseBuffer is an http.ResponseWriter which holds the response status
+// and body in memory, so it can be inspected before reaching the client.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// newResponseBuffer returns a responseBuffer which writes headers to the
+// given header map. If nil, a new header map is allocated.
+func newResponseBuffer(header http.Header) *responseBuffer {
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &responseBuffer{header: header}
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) WriteHeader(status int) {
+	if rb.status == 0 {
+		rb.status = status
+	}
+}
+
+func (rb *responseBuffer) Write(p []byte) (int, error) {
+	rb.WriteHeader(http.StatusOK)
+
+	return rb.body.Write(p)
+}
+
+// Status returns the status code written, which defaults to 200 OK if the
+// handler did not write one.
+func (rb *responseBuffer) Status() int {
+	if rb.status == 0 {
+		return http.StatusOK
+	}
+
+	return rb.status
+}
+
+// flush writes the buffered status and body to w. Headers are not copied, as
+// the buffer is expected to share its header map with w.
+func (rb *responseBuffer) flush(w http.ResponseWriter) error {
+	if rb.status == 0 && rb.body.Len() == 0 {
+		return nil
+	}
+
+	w.WriteHeader(rb.Status())
+
+	_, err := w.Write(rb.body.Bytes())
+	return err
+}
@@ -3,6 +3,7 @@ package crpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/xeipuuv/gojsonschema"
@@ -32,6 +34,9 @@ type Request struct {
 	Header        http.Header
 
 	ctx context.Context
+
+	// deprecation is set by Serve when the method called is deprecated
+	deprecation *Deprecation
 }
 
 // Context returns the requests context from the transport.
@@ -125,6 +130,7 @@ type WrappedFunc struct {
 	Handler       HandlerFunc
 	AcceptsInput  bool
 	ReturnsResult bool
+	Streams       bool
 }
 
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -137,6 +143,17 @@ var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
 // func(ctx context.Context, request *T) (err error)
 // func(ctx context.Context) (response *T, err error)
 // func(ctx context.Context) (err error)
+//
+// The response may also be streamed to the client as newline delimited JSON
+// (application/x-ndjson) by returning a channel or an iterator:
+//
+// func(ctx context.Context, request *T) (response <-chan *T, err error)
+// func(ctx context.Context, request *T) (response func(yield func(*T, error) bool), err error)
+//
+// The iterator form is iter.Seq2[*T, error] from Go 1.23. Each result is sent
+// to the client as a {"result": {...}} record, and if an iterator yields an
+// error once the stream has started, it is sent as a final {"error": {...}}
+// record.
 func Wrap(fn interface{}) (*WrappedFunc, error) {
 	// prevent re-reflection of type that is already a HandlerFunc
 	if _, ok := fn.(HandlerFunc); ok {
@@ -166,6 +183,7 @@ func Wrap(fn interface{}) (*WrappedFunc, error) {
 	// resolve function parameter pointers to underlying type for use with
 	// reflect.New (which will return pointers).
 	var reqT, resT reflect.Type = nil, nil
+	var stream streamFunc
 
 	if t.NumIn() == 2 {
 		if t.In(1).Kind() != reflect.Ptr {
@@ -181,10 +199,19 @@ func Wrap(fn interface{}) (*WrappedFunc, error) {
 	if t.NumOut() == 2 {
 		var err error
 
-		resT, err = wrapReturn(t.Out(0))
+		stream, err = wrapStream(t.Out(0))
 		if err != nil {
 			return nil, err
 		}
+
+		if stream != nil {
+			resT = t.Out(0)
+		} else {
+			resT, err = wrapReturn(t.Out(0))
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	hn := func(w http.ResponseWriter, r *Request) error {
@@ -192,25 +219,15 @@ func Wrap(fn interface{}) (*WrappedFunc, error) {
 		var inputs []reflect.Value
 
 		if reqT == nil {
-			if r.Body != nil {
-				i, err := r.Body.Read(make([]byte, 1))
-				if i != 0 || err != io.EOF {
-					return cher.New(cher.BadRequest, nil, cher.New("unexpected_request_body", nil))
-				}
+			if err := expectNoRequestBody(r); err != nil {
+				return err
 			}
 
 			inputs = []reflect.Value{ctx}
 		} else {
-			if r.Body == nil {
-				return cher.New(cher.BadRequest, nil, cher.New("missing_request_body", nil))
-			}
-
 			req := reflect.New(reqT)
-			err := json.NewDecoder(r.Body).Decode(req.Interface())
-			if err == io.EOF {
-				return cher.New(cher.BadRequest, nil, cher.New("missing_request_body", nil))
-			} else if err != nil {
-				return fmt.Errorf("crpc: json decoder error: %w", err)
+			if err := decodeRequestBody(r, req.Interface()); err != nil {
+				return err
 			}
 
 			inputs = []reflect.Value{ctx, req}
@@ -224,17 +241,10 @@ func Wrap(fn interface{}) (*WrappedFunc, error) {
 
 		if len(res) == 1 {
 			w.WriteHeader(http.StatusNoContent)
+		} else if stream != nil {
+			return stream(w, r, res[0])
 		} else if len(res) == 2 {
-			enc := json.NewEncoder(w)
-			enc.SetEscapeHTML(false)
-			err := enc.Encode(res[0].Interface())
-			if err != nil {
-				if strings.Contains(err.Error(), "broken pipe") {
-					return nil
-				}
-
-				return err
-			}
+			return encodeResponseBody(w, r, res[0].Interface())
 		}
 
 		return nil
@@ -244,9 +254,62 @@ func Wrap(fn interface{}) (*WrappedFunc, error) {
 		Handler:       hn,
 		AcceptsInput:  reqT != nil,
 		ReturnsResult: resT != nil,
+		Streams:       stream != nil,
 	}, nil
 }
 
+// expectNoRequestBody returns an error if the request has a body, for
+// handlers which do not accept input.
+func expectNoRequestBody(r *Request) error {
+	if r.Body != nil {
+		i, err := r.Body.Read(make([]byte, 1))
+		if i != 0 || err != io.EOF {
+			return cher.New(cher.BadRequest, nil, cher.New("unexpected_request_body", nil))
+		}
+	}
+
+	return nil
+}
+
+// decodeRequestBody decodes the request body into dst, with the codec of its
+// Content-Type.
+func decodeRequestBody(r *Request, dst interface{}) error {
+	if r.Body == nil {
+		return cher.New(cher.BadRequest, nil, cher.New("missing_request_body", nil))
+	}
+
+	c := requestCodec(r)
+
+	err := c.Decode(r.Body, dst)
+	if err == io.EOF {
+		return cher.New(cher.BadRequest, nil, cher.New("missing_request_body", nil))
+	} else if err != nil && c != JSONCodec {
+		return invalidBody(c, err)
+	} else if err != nil {
+		return fmt.Errorf("crpc: json decoder error: %w", err)
+	}
+
+	return nil
+}
+
+// encodeResponseBody encodes src as the response body, with the codec
+// preferred by the Accept header of the request.
+func encodeResponseBody(w http.ResponseWriter, r *Request, src interface{}) error {
+	c := responseCodec(r)
+	setContentType(w, c)
+
+	err := c.Encode(w, src)
+	if err != nil {
+		if strings.Contains(err.Error(), "broken pipe") {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 func wrapReturn(t reflect.Type) (reflect.Type, error) {
 	switch t.Kind() {
 	case reflect.Ptr:
@@ -292,6 +355,15 @@ func MustWrap(fn interface{}) *WrappedFunc {
 type handler struct {
 	v  string
 	fn HandlerFunc
+
+	method        string
+	schema        gojsonschema.JSONLoader
+	returnsResult bool
+	streams       bool
+	permissions   *Permissions
+	deprecation   *Deprecation
+	timeout       time.Duration
+	browserDenied bool
 }
 
 // Server is an HTTP-compatible crpc handler.
@@ -302,6 +374,24 @@ type Server struct {
 	// AuthenticationMiddleware is configured, the server will panic.
 	AuthenticationMiddleware MiddlewareFunc
 
+	// ExposeCatalogue serves the method catalogue and OpenAPI document on
+	// GET /_catalogue and GET /_catalogue/openapi.json. These endpoints are
+	// not subject to authentication, so only enable this where the list of
+	// methods and their schemas may be made public.
+	ExposeCatalogue bool
+
+	// Batch enables the batch route on POST /_batch, which executes several
+	// calls in a single HTTP request. It is disabled when nil.
+	Batch *BatchConfig
+
+	// Compression enables gzip and zstd compression of request and response
+	// bodies. It is disabled when nil.
+	Compression *CompressionConfig
+
+	// CORS allows browsers on other origins to call the server, answering
+	// preflight requests automatically. It is disabled when nil.
+	CORS *CORSConfig
+
 	// methods = version -> method -> HandlerFunc
 	registeredVersionMethods map[string]map[string]*handler
 	registeredPreviewMethods map[string]*handler
@@ -311,7 +401,8 @@ type Server struct {
 	mw []MiddlewareFunc
 }
 
-// NewServer returns a new RPC Server with an optional exception tracker.
+// NewServer returns a new RPC Server. Panics can be recovered and reported to
+// an exception tracker with the Recover middleware.
 func NewServer(auth MiddlewareFunc) *Server {
 	return &Server{
 		AuthenticationMiddleware: auth,
@@ -367,13 +458,20 @@ func (s *Server) Register(method, version string, schema gojsonschema.JSONLoader
 		}
 	}
 
-	s.RegisterFunc(method, version, schema, &wrapped.Handler, mw...)
+	s.register(method, version, schema, &wrapped.Handler, wrapped, mw...)
 }
 
 // RegisterFunc associates a method name and version with a HandlerFunc,
 // and optional middleware. This function is not thread safe and must be run in
 // serial if called multiple times.
+//
+// As the response of a HandlerFunc cannot be determined ahead of time, it is
+// assumed to return a result when described in the catalogue.
 func (s *Server) RegisterFunc(method, version string, schema gojsonschema.JSONLoader, fn *HandlerFunc, mw ...MiddlewareFunc) {
+	s.register(method, version, schema, fn, &WrappedFunc{ReturnsResult: true}, mw...)
+}
+
+func (s *Server) register(method, version string, schema gojsonschema.JSONLoader, fn *HandlerFunc, wrapped *WrappedFunc, mw ...MiddlewareFunc) {
 	if s.registeredVersionMethods == nil {
 		s.registeredVersionMethods = make(map[string]map[string]*handler)
 	}
@@ -403,15 +501,24 @@ func (s *Server) RegisterFunc(method, version string, schema gojsonschema.JSONLo
 	if fn == nil {
 		s.setRoute(version, method, nil)
 	} else {
+		hn := &handler{
+			v: version,
+
+			method:        method,
+			schema:        schema,
+			returnsResult: wrapped.ReturnsResult,
+			streams:       wrapped.Streams,
+		}
+
 		if schema != nil {
 			compiledSchema, err := gojsonschema.NewSchemaLoader().Compile(schema)
 			if err != nil {
 				panic(fmt.Sprintf("json schema error in %s: %s", method, err))
 			}
 
-			mw = append([]MiddlewareFunc{s.AuthenticationMiddleware, Validate(compiledSchema)}, mw...)
+			mw = append([]MiddlewareFunc{s.AuthenticationMiddleware, hn.authorize, Validate(compiledSchema)}, mw...)
 		} else {
-			mw = append([]MiddlewareFunc{s.AuthenticationMiddleware}, mw...)
+			mw = append([]MiddlewareFunc{s.AuthenticationMiddleware, hn.authorize}, mw...)
 		}
 
 		// This wraps the middleware funcs inside each one in reverse order
@@ -425,7 +532,9 @@ func (s *Server) RegisterFunc(method, version string, schema gojsonschema.JSONLo
 			fn = &p
 		}
 
-		s.setRoute(version, method, &handler{version, *fn})
+		hn.fn = *fn
+
+		s.setRoute(version, method, hn)
 	}
 
 	s.buildRoutes()
@@ -551,9 +660,33 @@ func (s *Server) Serve(res http.ResponseWriter, req *Request) error {
 		return cher.New(cher.NotFound, cher.M{"method": req.Method, "version": req.Version})
 	}
 
+	if hn.browserDenied && req.BrowserOrigin != "" {
+		return cher.New(cher.AccessDenied, nil, cher.New("browser_access_denied", nil))
+	}
+
 	// append latest version to Cuvva Endpoint Status
 	appendCuvvaEndpointStatus(res, req.Version, hn.v)
 
+	if hn.deprecation != nil {
+		appendDeprecationHeaders(res, hn.deprecation)
+
+		if hn.deprecation.sunset(time.Now()) {
+			return cher.New(cher.NoLongerSupported, nil)
+		}
+
+		req.deprecation = hn.deprecation
+	}
+
+	timeout, ok, err := requestTimeout(req, hn.timeout)
+	if err != nil {
+		return err
+	} else if ok {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+
+		req.WithContext(ctx)
+	}
+
 	fn := hn.fn
 
 	return fn(res, req)
@@ -594,6 +727,27 @@ func appendCuvvaEndpointStatus(w http.ResponseWriter, requestedVersion, resolved
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.ExposeCatalogue && r.Method == "GET" {
+		switch r.URL.Path {
+		case CataloguePath:
+			s.serveCatalogue(w, r)
+			return
+
+		case OpenAPIPath:
+			s.serveOpenAPI(w, r)
+			return
+		}
+	}
+
+	if s.CORS != nil {
+		if isPreflight(r) {
+			s.servePreflight(w, r)
+			return
+		}
+
+		s.CORS.appendCORSHeaders(w, r)
+	}
+
 	if r.Method != "POST" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -604,6 +758,18 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.Compression != nil {
+		cw, closeWriter := s.Compression.compressResponse(w, r)
+		defer closeWriter()
+
+		w = cw
+
+		if err := s.Compression.decompressRequest(r); err != nil {
+			s.writeError(w, err)
+			return
+		}
+	}
+
 	req := &Request{
 		Body: r.Body,
 
@@ -615,6 +781,11 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
+	if s.Batch != nil && r.URL.Path == BatchPath {
+		s.writeError(w, s.serveBatch(w, req))
+		return
+	}
+
 	var ok bool
 	req.Method, req.Version, ok = requestPath(r.URL.Path)
 	if !ok {
@@ -645,36 +816,49 @@ func (s *Server) writeError(w http.ResponseWriter, err error) {
 		return
 	}
 
-	var body cher.E
+	// the error has already been sent to the client within a stream
+	var interrupted *streamInterruptedError
+	if errors.As(err, &interrupted) {
+		return
+	}
+
+	body := coerceError(err)
 
-	switch err := err.(type) {
-	case cher.E:
-		body = err
+	w.WriteHeader(body.StatusCode())
 
-	case *json.SyntaxError:
-		body = cher.New(
+	json.NewEncoder(w).Encode(body)
+}
+
+// coerceError converts an error returned by a handler into the cher.E
+// returned to the client. Errors are resolved through the whole chain of
+// wrapped errors, using any translators registered with cher.
+func coerceError(err error) cher.E {
+	if body, ok := cher.Translate(err); ok {
+		return body
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return cher.New(
 			"invalid_json",
 			cher.M{
-				"error":  err.Error(),
-				"offset": err.Offset,
+				"error":  syntaxErr.Error(),
+				"offset": syntaxErr.Offset,
 			},
 		)
+	}
 
-	case *json.UnmarshalTypeError:
-		body = cher.New(
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return cher.New(
 			"invalid_json",
 			cher.M{
-				"expected": err.Type.Kind().String(),
-				"actual":   err.Value,
-				"name":     err.Field,
+				"expected": typeErr.Type.Kind().String(),
+				"actual":   typeErr.Value,
+				"name":     typeErr.Field,
 			},
 		)
-
-	default:
-		body = cher.New(cher.Unknown, nil)
 	}
 
-	w.WriteHeader(body.StatusCode())
-
-	json.NewEncoder(w).Encode(body)
+	return cher.New(cher.Unknown, nil)
 }
@@ -3,11 +3,14 @@ package crpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/stretchr/testify/assert"
 	"github.com/xeipuuv/gojsonschema"
 )
@@ -608,3 +611,43 @@ func TestRequestHeadersIntegration(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "true|value1|")
 	})
 }
+
+type testDomainError struct{}
+
+func (testDomainError) Error() string { return "domain error" }
+
+func TestWrappedErrorsAreResolved(t *testing.T) {
+	t.Cleanup(cher.RegisterTranslator(cher.TranslatorFor(func(testDomainError) cher.E {
+		return cher.New("domain_error", nil)
+	})))
+
+	tests := []struct {
+		Name   string
+		Error  error
+		Status int
+		Body   string
+	}{
+		{"Cher", cher.New(cher.NotFound, nil), http.StatusNotFound, "{\"code\":\"not_found\"}\n"},
+		{"WrappedCher", fmt.Errorf("get policy: %w", cher.New(cher.NotFound, nil)), http.StatusNotFound, "{\"code\":\"not_found\"}\n"},
+		{"Translated", fmt.Errorf("get policy: %w", testDomainError{}), http.StatusBadRequest, "{\"code\":\"domain_error\"}\n"},
+		{"WrappedJSON", fmt.Errorf("decode: %w", &json.SyntaxError{Offset: 1}), http.StatusBadRequest, "{\"code\":\"invalid_json\",\"meta\":{\"error\":\"\",\"offset\":1}}\n"},
+		{"Unknown", errors.New("foo"), http.StatusInternalServerError, "{\"code\":\"unknown\"}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			zs := NewServer(UnsafeNoAuthentication)
+			zs.Register("fail", "2019-01-01", nil, func(context.Context) error {
+				return test.Error
+			})
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/2019-01-01/fail", nil)
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.Body, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package crpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+)
+
+// StreamContentType is the Content-Type of streamed responses, where each
+// record is a JSON document terminated by a newline.
+const StreamContentType = "application/x-ndjson"
+
+// streamRecord is a record of a streamed response, holding either a result
+// or, as the final record of a stream interrupted by an error, the error.
+// Results are always enveloped, so they can never be mistaken for an error.
+type streamRecord struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  *cher.E     `json:"error,omitempty"`
+}
+
+// streamInterruptedError is returned by a streaming handler when an error
+// has already been sent to the client as the final record of a stream.
+type streamInterruptedError struct {
+	cause error
+}
+
+func (e *streamInterruptedError) Error() string {
+	return "crpc: stream interrupted: " + e.cause.Error()
+}
+
+func (e *streamInterruptedError) Unwrap() error {
+	return e.cause
+}
+
+type streamFunc func(w http.ResponseWriter, r *Request, v reflect.Value) error
+
+// wrapStream returns a streamFunc if t is a channel or iterator of a
+// supported return type, or nil if t cannot be streamed.
+func wrapStream(t reflect.Type) (streamFunc, error) {
+	switch {
+	case t.Kind() == reflect.Chan && t.ChanDir()&reflect.RecvDir != 0:
+		if _, err := wrapReturn(t.Elem()); err != nil {
+			return nil, err
+		}
+
+		return streamChan, nil
+
+	case isIterator(t):
+		if _, err := wrapReturn(t.In(0).In(0)); err != nil {
+			return nil, err
+		}
+
+		return streamIterator, nil
+	}
+
+	return nil, nil
+}
+
+// isIterator reports whether t is func(yield func(T, error) bool), the
+// underlying type of iter.Seq2[T, error] from Go 1.23.
+func isIterator(t reflect.Type) bool {
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+
+	yield := t.In(0)
+
+	return yield.Kind() == reflect.Func &&
+		yield.NumIn() == 2 && yield.NumOut() == 1 &&
+		yield.In(1) == errorType && yield.Out(0).Kind() == reflect.Bool
+}
+
+func streamChan(w http.ResponseWriter, r *Request, v reflect.Value) error {
+	sw := newStreamWriter(w)
+
+	if v.IsNil() {
+		return sw.close(nil)
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r.Context().Done())},
+		{Dir: reflect.SelectRecv, Chan: v},
+	}
+
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 0 {
+			return sw.close(r.Context().Err())
+		} else if !ok {
+			return sw.close(nil)
+		}
+
+		if err := sw.write(item.Interface()); err != nil {
+			return sw.abort(err)
+		}
+	}
+}
+
+func streamIterator(w http.ResponseWriter, r *Request, v reflect.Value) error {
+	sw := newStreamWriter(w)
+
+	if v.IsNil() {
+		return sw.close(nil)
+	}
+
+	var streamErr, writeErr error
+
+	yield := reflect.MakeFunc(v.Type().In(0), func(args []reflect.Value) []reflect.Value {
+		if err := args[1]; !err.IsNil() {
+			streamErr = err.Interface().(error)
+		} else if err := r.Context().Err(); err != nil {
+			streamErr = err
+		} else {
+			writeErr = sw.write(args[0].Interface())
+		}
+
+		return []reflect.Value{reflect.ValueOf(streamErr == nil && writeErr == nil)}
+	})
+
+	v.Call([]reflect.Value{yield})
+
+	if writeErr != nil {
+		return sw.abort(writeErr)
+	}
+
+	return sw.close(streamErr)
+}
+
+// streamWriter writes records as newline delimited JSON, flushing each
+// record to the client as it is written.
+type streamWriter struct {
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	flusher http.Flusher
+	started bool
+}
+
+func newStreamWriter(w http.ResponseWriter) *streamWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	flusher, _ := w.(http.Flusher)
+
+	return &streamWriter{
+		w:       w,
+		enc:     enc,
+		flusher: flusher,
+	}
+}
+
+func (sw *streamWriter) start() {
+	if sw.started {
+		return
+	}
+
+	sw.w.Header().Set("Content-Type", StreamContentType)
+	sw.w.WriteHeader(http.StatusOK)
+	sw.started = true
+}
+
+func (sw *streamWriter) write(v interface{}) error {
+	return sw.writeRecord(streamRecord{Result: v})
+}
+
+func (sw *streamWriter) writeRecord(rec streamRecord) error {
+	sw.start()
+
+	if err := sw.enc.Encode(rec); err != nil {
+		return err
+	}
+
+	if sw.flusher != nil {
+		sw.flusher.Flush()
+	}
+
+	return nil
+}
+
+// close ends the stream. If err is not nil and the stream has started, err
+// is sent to the client as the final record of the stream. Otherwise err is
+// returned to be handled as any other error.
+func (sw *streamWriter) close(err error) error {
+	if err == nil {
+		sw.start()
+		return nil
+	} else if !sw.started {
+		return err
+	}
+
+	body := coerceError(err)
+
+	if writeErr := sw.writeRecord(streamRecord{Error: &body}); writeErr != nil {
+		return sw.abort(writeErr)
+	}
+
+	return &streamInterruptedError{err}
+}
+
+// abort ends a stream which can no longer be written to.
+func (sw *streamWriter) abort(err error) error {
+	if strings.Contains(err.Error(), "broken pipe") {
+		return nil
+	}
+
+	return &streamInterruptedError{err}
+}
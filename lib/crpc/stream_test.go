@@ -0,0 +1,183 @@
+//nolint:bodyclose // incorrect
+package crpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamChanHandler(n int) func(context.Context) (<-chan *testResponse, error) {
+	return func(ctx context.Context) (<-chan *testResponse, error) {
+		ch := make(chan *testResponse)
+
+		go func() {
+			defer close(ch)
+
+			for i := 0; i < n; i++ {
+				select {
+				case ch <- &testResponse{Message: "chan"}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return ch, nil
+	}
+}
+
+func streamIterHandler(n int, err error) func(context.Context) (func(yield func(*testResponse, error) bool), error) {
+	return func(context.Context) (func(yield func(*testResponse, error) bool), error) {
+		return func(yield func(*testResponse, error) bool) {
+			for i := 0; i < n; i++ {
+				if !yield(&testResponse{Message: "iter"}, nil) {
+					return
+				}
+			}
+
+			if err != nil {
+				yield(nil, err)
+			}
+		}, nil
+	}
+}
+
+func TestWrapStream(t *testing.T) {
+	wrapped, err := Wrap(streamChanHandler(0))
+	require.NoError(t, err)
+	assert.True(t, wrapped.Streams)
+	assert.True(t, wrapped.ReturnsResult)
+
+	wrapped, err = Wrap(streamIterHandler(0, nil))
+	require.NoError(t, err)
+	assert.True(t, wrapped.Streams)
+
+	_, err = Wrap(func(context.Context) (<-chan string, error) { return nil, nil })
+	assert.EqualError(t, err, "unsupported return type, expected *struct or slice; got string")
+
+	_, err = Wrap(func(context.Context) (chan<- *testResponse, error) { return nil, nil })
+	assert.EqualError(t, err, "unsupported return type, expected *struct or slice; got chan<- *crpc.testResponse")
+}
+
+func TestServeStream(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	zs.Register("stream_chan", "preview", nil, streamChanHandler(2))
+	zs.Register("stream_iter", "preview", nil, streamIterHandler(2, nil))
+	zs.Register("stream_empty", "preview", nil, streamIterHandler(0, nil))
+	zs.Register("stream_error", "preview", nil, streamIterHandler(1, cher.New("export_failed", nil)))
+	zs.Register("stream_error_first", "preview", nil, streamIterHandler(0, cher.New("export_failed", nil)))
+	zs.Register("stream_unknown_error", "preview", nil, streamIterHandler(1, errors.New("boom")))
+
+	tests := []struct {
+		Method string
+		Status int
+		Body   string
+	}{
+		{"stream_chan", http.StatusOK, "{\"result\":{\"message\":\"chan\"}}\n{\"result\":{\"message\":\"chan\"}}\n"},
+		{"stream_iter", http.StatusOK, "{\"result\":{\"message\":\"iter\"}}\n{\"result\":{\"message\":\"iter\"}}\n"},
+		{"stream_empty", http.StatusOK, ""},
+		{"stream_error", http.StatusOK, "{\"result\":{\"message\":\"iter\"}}\n{\"error\":{\"code\":\"export_failed\"}}\n"},
+		{"stream_error_first", http.StatusBadRequest, "{\"code\":\"export_failed\"}\n"},
+		{"stream_unknown_error", http.StatusOK, "{\"result\":{\"message\":\"iter\"}}\n{\"error\":{\"code\":\"unknown\"}}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/preview/"+test.Method, nil)
+
+			zs.ServeHTTP(w, r)
+
+			assert.Equal(t, test.Status, w.Code)
+			assert.Equal(t, test.Body, w.Body.String())
+
+			if test.Status == http.StatusOK {
+				assert.Equal(t, StreamContentType, w.Header().Get("Content-Type"))
+				assert.True(t, w.Flushed || test.Body == "")
+			}
+		})
+	}
+}
+
+type testErrorFieldResponse struct {
+	Error *cher.E `json:"error"`
+}
+
+func TestClientStream(t *testing.T) {
+	zs := NewServer(UnsafeNoAuthentication)
+
+	zs.Register("stream_error_field", "preview", nil, func(context.Context) (func(yield func(*testErrorFieldResponse, error) bool), error) {
+		return func(yield func(*testErrorFieldResponse, error) bool) {
+			yield(&testErrorFieldResponse{Error: &cher.E{Code: "declined"}}, nil)
+		}, nil
+	})
+
+	zs.Register("stream_iter", "preview", nil, streamIterHandler(3, nil))
+	zs.Register("stream_error", "preview", nil, streamIterHandler(1, cher.New("export_failed", nil)))
+
+	hs := httptest.NewServer(zs)
+	defer hs.Close()
+
+	client := NewClient(context.Background(), hs.URL+"/", hs.Client())
+
+	t.Run("Complete", func(t *testing.T) {
+		stream, err := client.DoStream(context.Background(), "stream_iter", "preview", nil)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		var n int
+		var res testResponse
+
+		for stream.Next(&res) {
+			assert.Equal(t, "iter", res.Message)
+			n++
+		}
+
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("Interrupted", func(t *testing.T) {
+		stream, err := client.DoStream(context.Background(), "stream_error", "preview", nil)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		var n int
+		var res testResponse
+
+		for stream.Next(&res) {
+			n++
+		}
+
+		assert.Equal(t, cher.New("export_failed", nil), stream.Err())
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("ErrorField", func(t *testing.T) {
+		stream, err := client.DoStream(context.Background(), "stream_error_field", "preview", nil)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		var res testErrorFieldResponse
+
+		// a result with an error field is not mistaken for a stream error
+		require.True(t, stream.Next(&res))
+		assert.Equal(t, &cher.E{Code: "declined"}, res.Error)
+
+		assert.False(t, stream.Next(&res))
+		assert.NoError(t, stream.Err())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := client.DoStream(context.Background(), "missing", "preview", nil)
+		assert.Equal(t, cher.New(cher.NotFound, cher.M{"method": "missing", "version": "preview"}), err)
+	})
+}
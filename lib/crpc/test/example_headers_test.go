@@ -4,27 +4,26 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
 	"testing"
 
 	"github.com/cuvva/cuvva-public-go/lib/crpc"
+	"github.com/cuvva/cuvva-public-go/lib/crpc/crpctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-// ExampleRequest_Headers demonstrates how to read and manipulate HTTP headers
-// in CRPC request handlers.
+type headerDemoResponse struct {
+	Message string `json:"message"`
+}
+
+// TestExampleRequestHeaders demonstrates how to read and manipulate HTTP
+// headers in CRPC request handlers.
 func TestExampleRequestHeaders(t *testing.T) {
-	// Create a new CRPC server
-	server := crpc.NewServer(func(next crpc.HandlerFunc) crpc.HandlerFunc {
-		return func(res http.ResponseWriter, req *crpc.Request) error {
-			// Simple authentication middleware - just pass through
-			return next(res, req)
-		}
-	})
+	// Create a new in-memory CRPC server, bypassing authentication
+	server := crpctest.NewServer(t, crpctest.NoAuthentication)
 
 	// Register a handler that demonstrates header usage
-	server.Register("header_demo", "preview", nil, func(ctx context.Context) (*struct {
-		Message string `json:"message"`
-	}, error) {
+	server.Register("header_demo", "preview", nil, func(ctx context.Context) (*headerDemoResponse, error) {
 		// Get the request from context
 		req := crpc.GetRequestContext(ctx)
 		if req == nil {
@@ -41,27 +40,22 @@ func TestExampleRequestHeaders(t *testing.T) {
 		req.AddHeader("X-Debug", "header-demo")
 
 		// Return response with header information
-		return &struct {
-			Message string `json:"message"`
-		}{
+		return &headerDemoResponse{
 			Message: fmt.Sprintf("User-Agent: %s, Auth: %s, Custom: %s", userAgent, authorization, customHeader),
 		}, nil
 	})
 
-	// Create a test request with headers
-	w := httptest.NewRecorder()
-	r, _ := http.NewRequest("POST", "/preview/header_demo", nil)
-	r.Header.Set("User-Agent", "example-client/1.0")
-	r.Header.Set("Authorization", "Bearer token123")
-	r.Header.Set("X-Custom-Header", "custom-value")
-
-	// Handle the request
-	server.ServeHTTP(w, r)
+	// Call the handler with a real client, recording the response to
+	// testdata/TestExampleRequestHeaders.golden
+	client := server.GoldenClient()
 
-	fmt.Printf("Status: %d\n", w.Code)
-	fmt.Printf("Response: %s", w.Body.String())
+	var res headerDemoResponse
+	err := client.Do(context.Background(), "header_demo", "preview", nil, &res, func(r *http.Request) {
+		r.Header.Set("User-Agent", "example-client/1.0")
+		r.Header.Set("Authorization", "Bearer token123")
+		r.Header.Set("X-Custom-Header", "custom-value")
+	})
 
-	// Output:
-	// Status: 200
-	// Response: {"message":"User-Agent: example-client/1.0, Auth: Bearer token123, Custom: custom-value"}
+	require.NoError(t, err)
+	assert.Equal(t, "User-Agent: example-client/1.0, Auth: Bearer token123, Custom: custom-value", res.Message)
 }
@@ -1,6 +1,9 @@
 package mongodb
 
 import (
+	"errors"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -11,11 +14,14 @@ const (
 func HasErrorCode(err error, code int) bool {
 	var writeErrors []mongo.WriteError
 
-	switch v := err.(type) {
-	case mongo.WriteException:
-		writeErrors = append(writeErrors, v.WriteErrors...)
-	case mongo.BulkWriteException:
-		for _, err := range v.WriteErrors {
+	var writeErr mongo.WriteException
+	var bulkWriteErr mongo.BulkWriteException
+
+	switch {
+	case errors.As(err, &writeErr):
+		writeErrors = append(writeErrors, writeErr.WriteErrors...)
+	case errors.As(err, &bulkWriteErr):
+		for _, err := range bulkWriteErr.WriteErrors {
 			writeErrors = append(writeErrors, err.WriteError)
 		}
 	}
@@ -28,3 +34,13 @@ func HasErrorCode(err error, code int) bool {
 
 	return false
 }
+
+// TranslateDuplicateKey translates a duplicate key write error into a cher
+// conflict error, for use with cher.RegisterTranslator.
+func TranslateDuplicateKey(err error) (cher.E, bool) {
+	if HasErrorCode(err, ErrorCodeDuplicateKey) {
+		return cher.New(cher.Conflict, nil), true
+	}
+
+	return cher.E{}, false
+}
@@ -0,0 +1,38 @@
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTranslateDuplicateKey(t *testing.T) {
+	tests := []struct {
+		Name string
+
+		Error    error
+		Conflict bool
+	}{
+		{"NoError", nil, false},
+		{"NotMongoError", errors.New("foo"), false},
+		{"WriteException", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: ErrorCodeDuplicateKey}}}, true},
+		{"WrappedWriteException", fmt.Errorf("insert quote: %w", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: ErrorCodeDuplicateKey}}}), true},
+		{"BulkWriteException", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: mongo.WriteError{Code: ErrorCodeDuplicateKey}}}}, true},
+		{"OtherWriteError", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 121}}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e, ok := TranslateDuplicateKey(test.Error)
+
+			assert.Equal(t, test.Conflict, ok)
+			if test.Conflict {
+				assert.Equal(t, cher.Conflict, e.Code)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package pg
 
 import (
+	"errors"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/jackc/pgconn"
 	"github.com/lib/pq"
 )
@@ -46,12 +49,25 @@ func IsPLv8Error(err error) (msg string, ok bool) {
 	return
 }
 
+// TranslateDuplicate translates a unique_constraint_violation into a cher
+// conflict error, for use with cher.RegisterTranslator.
+func TranslateDuplicate(err error) (cher.E, bool) {
+	if _, ok := IsDuplicate(err); ok {
+		return cher.New(cher.Conflict, nil), true
+	}
+
+	return cher.E{}, false
+}
+
 func convertError(err error) (pe Error) {
-	switch e := err.(type) {
-	case *pq.Error:
-		pe = Error{e.Severity, string(e.Code), e.Message, e.Constraint}
-	case *pgconn.PgError:
-		pe = Error{e.Severity, e.Code, e.Message, e.ConstraintName}
+	var pqErr *pq.Error
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.As(err, &pqErr):
+		pe = Error{pqErr.Severity, string(pqErr.Code), pqErr.Message, pqErr.Constraint}
+	case errors.As(err, &pgErr):
+		pe = Error{pgErr.Severity, pgErr.Code, pgErr.Message, pgErr.ConstraintName}
 	}
 	return
 }
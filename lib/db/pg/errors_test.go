@@ -2,8 +2,10 @@ package pg
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/jackc/pgconn"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
@@ -51,6 +53,15 @@ func TestIsDuplicate(t *testing.T) {
 			},
 			"events_pkey", true,
 		},
+		{
+			"Wrapped",
+			fmt.Errorf("insert event: %w", &pgconn.PgError{
+				Severity:       "ERROR",
+				Code:           "23505",
+				ConstraintName: "events_pkey",
+			}),
+			"events_pkey", true,
+		},
 	}
 
 	for _, test := range tests {
@@ -118,3 +129,13 @@ func TestIsPLv8Error(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslateDuplicate(t *testing.T) {
+	e, ok := TranslateDuplicate(fmt.Errorf("insert event: %w", &pq.Error{Severity: "ERROR", Code: "23505"}))
+	if assert.True(t, ok) {
+		assert.Equal(t, cher.Conflict, e.Code)
+	}
+
+	_, ok = TranslateDuplicate(errors.New("foo"))
+	assert.False(t, ok)
+}
@@ -12,18 +12,19 @@ type Client struct {
 	*jsonclient.Client
 }
 
-// NewClient creates a new vehicle service client.
-func NewClient(baseURL, key string) *Client {
+// NewClient creates a new vehicle service client, configured by any options,
+// such as jsonclient.WithInstrumentation.
+func NewClient(baseURL, key string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Transport: &roundTripper{key},
 		Timeout:   5 * time.Second,
 	}
 
 	return &Client{
-		jsonclient.NewClient(baseURL, httpClient),
+		jsonclient.NewClient(baseURL, httpClient, opts...),
 	}
 }
 
-func (c *Client) GetVehicleByVRM(ctx context.Context, vrm string) (res *Vehicle, err error) {
-	return res, c.Do(ctx, "POST", "vehicle-enquiry/v1/vehicles", nil, &VESVRMRequest{RegistrationNumber: vrm}, &res)
+func (c *Client) GetVehicleByVRM(ctx context.Context, vrm string) (*Vehicle, error) {
+	return jsonclient.Post[Vehicle](ctx, c.Client, "vehicle-enquiry/v1/vehicles", nil, &VESVRMRequest{RegistrationNumber: vrm})
 }
@@ -0,0 +1,49 @@
+package dvlaves
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient/cassette"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client of the UAT environment which replays the
+// interactions in fixture, or records them with CASSETTE_RECORD=1 and a UAT
+// key in DVLAVES_KEY. The cassette wraps the client's transport, so the key is
+// added after the interaction is recorded, and the registration number and
+// V5C issue date are redacted so fixtures hold no data about a vehicle's
+// keeper.
+func newTestClient(t *testing.T, fixture string) *Client {
+	c := NewClient(UATURI, os.Getenv("DVLAVES_KEY"))
+
+	rec := cassette.Use(t, fixture, cassette.RedactBodyFields("registrationNumber", "dateOfLastV5CIssued"))
+	rec.Transport = c.Client.Client.Transport
+	c.Client.Client.Transport = rec
+
+	return c
+}
+
+func TestClient_GetVehicleByVRM(t *testing.T) {
+	c := newTestClient(t, "testdata/get_vehicle_by_vrm.yaml")
+
+	vehicle, err := c.GetVehicleByVRM(context.Background(), "AA19AAA")
+	require.NoError(t, err)
+
+	assert.Equal(t, cassette.Redacted, vehicle.RegistrationNumber)
+	assert.Equal(t, "FORD", vehicle.Make)
+	assert.Equal(t, 2598, vehicle.EngineCapacity)
+	assert.Equal(t, TaxStatusTaxed, vehicle.TaxStatus)
+	assert.Equal(t, MOTStatusNotFound, vehicle.MOTStatus)
+
+	_, err = c.GetVehicleByVRM(context.Background(), "0000")
+
+	var cErr cher.E
+	if assert.ErrorAs(t, err, &cErr) {
+		assert.Equal(t, http.StatusBadRequest, cErr.Meta["httpStatus"])
+	}
+}
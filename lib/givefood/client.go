@@ -13,13 +13,13 @@ type Client struct {
 	client *jsonclient.Client
 }
 
-func NewClient(baseURL string) *Client {
+func NewClient(baseURL string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Timeout: 5 * time.Second,
 	}
 
 	return &Client{
-		jsonclient.NewClient(baseURL, httpClient),
+		jsonclient.NewClient(baseURL, httpClient, opts...),
 	}
 }
 
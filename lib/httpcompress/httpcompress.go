@@ -0,0 +1,220 @@
+// Package httpcompress implements the gzip and zstd content codings used to
+// compress HTTP request and response bodies.
+package httpcompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings supported, in order of preference.
+const (
+	Zstd = "zstd"
+	Gzip = "gzip"
+
+	// Identity is the content coding of an uncompressed body.
+	Identity = "identity"
+)
+
+// AcceptEncoding is the Accept-Encoding header value advertising every
+// supported content coding.
+const AcceptEncoding = Zstd + ", " + Gzip
+
+// ErrUnsupportedEncoding is returned for content codings which are not
+// supported.
+var ErrUnsupportedEncoding = errors.New("httpcompress: unsupported content encoding")
+
+// Negotiate returns the preferred supported content coding accepted by an
+// Accept-Encoding header value, or Identity if none are accepted.
+func Negotiate(acceptEncoding string) string {
+	var best string
+	var bestQ float64
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, q := parseCoding(part)
+		if q <= 0 || (coding != Zstd && coding != Gzip) {
+			continue
+		}
+
+		// prefer zstd over gzip when equally weighted
+		if q > bestQ || (q == bestQ && coding == Zstd) {
+			best, bestQ = coding, q
+		}
+	}
+
+	if best == "" {
+		return Identity
+	}
+
+	return best
+}
+
+func parseCoding(part string) (coding string, q float64) {
+	coding, params, _ := strings.Cut(part, ";")
+	coding = strings.ToLower(strings.TrimSpace(coding))
+	q = 1
+
+	for _, param := range strings.Split(params, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.TrimSpace(key) != "q" {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return coding, 0
+		}
+
+		q = parsed
+	}
+
+	return coding, q
+}
+
+// NewReader returns a reader decompressing r with the given content coding.
+// The caller must close the returned reader, which does not close r.
+//
+// maxSize is the largest decompressed body expected, and bounds the memory
+// the decoder may allocate before any of the body is read, as a zstd frame
+// may declare a window far larger than its content. Frames with a larger
+// window are rejected, which LimitReader reports as the body being too large.
+// The decompressed size must still be limited with LimitReader.
+func NewReader(encoding string, r io.Reader, maxSize int64) (io.ReadCloser, error) {
+	switch strings.ToLower(encoding) {
+	case "", Identity:
+		return io.NopCloser(r), nil
+
+	case Gzip:
+		return gzip.NewReader(r)
+
+	case Zstd:
+		window := maxWindowSize(maxSize)
+
+		zr, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(window),
+			zstd.WithDecoderMaxMemory(window),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+}
+
+// maxWindowSize returns the largest zstd window needed to decode a body of up
+// to maxSize bytes, as encoders round the window up to a power of two.
+func maxWindowSize(maxSize int64) uint64 {
+	window := uint64(zstd.MinWindowSize)
+	for window < uint64(maxSize) && window < zstd.MaxWindowSize {
+		window <<= 1
+	}
+
+	return window
+}
+
+// Compress returns body compressed with the given content coding. Unlike
+// NewWriter, zstd frames declare their content size and a window no larger
+// than needed, so they can be decoded by readers limited to small bodies.
+func Compress(encoding string, body []byte) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case Gzip:
+		var buf bytes.Buffer
+
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(body); err != nil {
+			return nil, err
+		}
+
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+
+	case Zstd:
+		zw, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return zw.EncodeAll(body, nil), zw.Close()
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+}
+
+// Writer is a compressing writer which can be flushed.
+type Writer interface {
+	io.WriteCloser
+
+	// Flush writes any buffered data to the underlying writer.
+	Flush() error
+}
+
+// NewWriter returns a writer compressing to w with the given content coding.
+// The caller must close the returned writer to write any buffered data, which
+// does not close w.
+func NewWriter(encoding string, w io.Writer) (Writer, error) {
+	switch strings.ToLower(encoding) {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+
+	case Zstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+}
+
+// LimitReader returns a reader which reads from r, returning err once more
+// than n bytes have been read, or once r is found to need more memory than
+// allowed by NewReader. It guards against bodies which decompress to far more
+// than their compressed size.
+func LimitReader(r io.ReadCloser, n int64, err error) io.ReadCloser {
+	return &limitReader{r: r, n: n, err: err}
+}
+
+type limitReader struct {
+	r   io.ReadCloser
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+
+	// read one byte past the limit to detect oversized bodies
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return n, l.err
+	}
+
+	l.n -= int64(n)
+
+	if l.n < 0 {
+		return n + int(l.n), l.err
+	}
+
+	return n, err
+}
+
+func (l *limitReader) Close() error {
+	return l.r.Close()
+}
@@ -0,0 +1,145 @@
+package httpcompress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		AcceptEncoding string
+		Encoding       string
+	}{
+		{"", Identity},
+		{"br", Identity},
+		{"gzip", Gzip},
+		{"gzip, deflate, br", Gzip},
+		{"zstd", Zstd},
+		{"gzip, zstd", Zstd},
+		{"GZIP", Gzip},
+		{"gzip;q=1.0, zstd;q=0.5", Gzip},
+		{"zstd;q=0, gzip", Gzip},
+		{"gzip;q=0", Identity},
+		{"gzip;q=invalid", Identity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.AcceptEncoding, func(t *testing.T) {
+			assert.Equal(t, test.Encoding, Negotiate(test.AcceptEncoding))
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	body := strings.Repeat(`{"message":"hello"}`, 100)
+
+	for _, encoding := range []string{Gzip, Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			w, err := NewWriter(encoding, &buf)
+			require.NoError(t, err)
+
+			_, err = w.Write([]byte(body))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			assert.Less(t, buf.Len(), len(body))
+
+			r, err := NewReader(encoding, &buf, int64(len(body)))
+			require.NoError(t, err)
+			defer r.Close()
+
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(b))
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := NewReader("br", nil, 0)
+		assert.True(t, errors.Is(err, ErrUnsupportedEncoding))
+
+		_, err = Compress("br", nil)
+		assert.True(t, errors.Is(err, ErrUnsupportedEncoding))
+
+		_, err = NewWriter("br", nil)
+		assert.True(t, errors.Is(err, ErrUnsupportedEncoding))
+	})
+}
+
+func TestLimitReader(t *testing.T) {
+	errTooLarge := errors.New("too large")
+
+	tests := []struct {
+		Name  string
+		Body  string
+		Limit int64
+		Error error
+	}{
+		{"UnderLimit", "hello", 10, nil},
+		{"AtLimit", "hello", 5, nil},
+		{"OverLimit", "hello world", 5, errTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			r := LimitReader(io.NopCloser(strings.NewReader(test.Body)), test.Limit, errTooLarge)
+
+			b, err := io.ReadAll(r)
+			assert.Equal(t, test.Error, err)
+
+			if test.Error == nil {
+				assert.Equal(t, test.Body, string(b))
+			} else {
+				assert.Len(t, b, int(test.Limit))
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("a", 1500)
+
+	for _, encoding := range []string{Gzip, Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(encoding, []byte(body))
+			require.NoError(t, err)
+
+			// the smallest limit the body fits within
+			r, err := NewReader(encoding, bytes.NewReader(compressed), int64(len(body)))
+			require.NoError(t, err)
+			defer r.Close()
+
+			b, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(b))
+		})
+	}
+}
+
+func TestOversizedWindow(t *testing.T) {
+	errTooLarge := errors.New("too large")
+
+	// a zstd frame declaring a 256MiB window, containing a single byte
+	frame := []byte{
+		0x28, 0xb5, 0x2f, 0xfd, // magic number
+		0x00,             // frame header descriptor, without content size
+		18 << 3,          // window descriptor, 1 << (10 + 18) bytes
+		0x09, 0x00, 0x00, // last raw block of 1 byte
+		'a',
+	}
+
+	r, err := NewReader(Zstd, bytes.NewReader(frame), 1<<20)
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = io.ReadAll(LimitReader(r, 1<<20, errTooLarge))
+	assert.Equal(t, errTooLarge, err)
+}
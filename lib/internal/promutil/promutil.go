@@ -0,0 +1,26 @@
+// Package promutil contains helpers for the Prometheus metrics of the
+// middleware and clients in lib.
+package promutil
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterOrExisting registers c with r, returning the collector already
+// registered in its place if there is one, so metrics can be set up by more
+// than one instance of a middleware or client. It panics if c cannot be
+// registered for any other reason.
+func RegisterOrExisting(r prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := r.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector
+		}
+
+		panic(err)
+	}
+
+	return c
+}
@@ -0,0 +1,396 @@
+package jsonclient
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/internal/promutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultBreakerWindow is the default period over which the failure
+	// rate of requests to a host is measured.
+	DefaultBreakerWindow = 10 * time.Second
+
+	// DefaultBreakerMinRequests is the default number of requests to a host
+	// within the window before its circuit can open.
+	DefaultBreakerMinRequests = 20
+
+	// DefaultBreakerFailureRate is the default proportion of requests to a
+	// host within the window which must fail for its circuit to open.
+	DefaultBreakerFailureRate = 0.5
+
+	// DefaultBreakerCoolDown is the default time a circuit stays open before
+	// trial requests are allowed.
+	DefaultBreakerCoolDown = 30 * time.Second
+
+	// breakerBuckets is the number of buckets the window is divided into, so
+	// old outcomes expire gradually rather than all at once.
+	breakerBuckets = 10
+)
+
+// BreakerState is the state of the circuit to a host.
+type BreakerState int
+
+const (
+	// BreakerClosed allows requests, while measuring their failure rate.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen fails requests fast, without sending them, until the
+	// cool-down has passed.
+	BreakerOpen
+
+	// BreakerHalfOpen allows a limited number of trial requests, closing the
+	// circuit if they succeed or opening it again if any fail.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	}
+
+	return "unknown"
+}
+
+// BreakerConfig configures a CircuitBreaker.
+type BreakerConfig struct {
+	// Window is the period over which the failure rate of requests to a host
+	// is measured. Defaults to DefaultBreakerWindow if zero.
+	Window time.Duration
+
+	// MinRequests is the number of requests to a host within the window
+	// before its circuit can open. Defaults to DefaultBreakerMinRequests if
+	// zero.
+	MinRequests int
+
+	// FailureRate is the proportion of requests to a host within the window,
+	// between 0 and 1, which must fail for its circuit to open. Defaults to
+	// DefaultBreakerFailureRate if zero.
+	FailureRate float64
+
+	// CoolDown is how long a circuit stays open before trial requests are
+	// allowed. Defaults to DefaultBreakerCoolDown if zero.
+	CoolDown time.Duration
+
+	// HalfOpenRequests is the number of trial requests allowed while a
+	// circuit is half-open, all of which must succeed for it to close.
+	// Defaults to 1 if zero.
+	HalfOpenRequests int
+
+	// IsFailure reports whether a request which returned the response or
+	// transport error counts as a failure. Defaults to treating transport
+	// errors and 5xx responses as failures if nil.
+	IsFailure func(res *http.Response, err error) bool
+
+	// Registerer records the state of each circuit and its changes, if set.
+	Registerer prometheus.Registerer
+}
+
+// CircuitBreaker stops requests being sent to hosts which are failing, so
+// callers fail fast with a circuit_open error rather than waiting on
+// timeouts. Circuits are kept per host, so a CircuitBreaker can be shared by
+// every client of the same host.
+type CircuitBreaker struct {
+	cfg     BreakerConfig
+	metrics *breakerMetrics
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+
+	now func() time.Time
+}
+
+// NewCircuitBreaker returns a CircuitBreaker configured by cfg.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultBreakerWindow
+	}
+
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = DefaultBreakerMinRequests
+	}
+
+	if cfg.FailureRate <= 0 {
+		cfg.FailureRate = DefaultBreakerFailureRate
+	}
+
+	if cfg.CoolDown <= 0 {
+		cfg.CoolDown = DefaultBreakerCoolDown
+	}
+
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isFailure
+	}
+
+	return &CircuitBreaker{
+		cfg:     cfg,
+		metrics: newBreakerMetrics(cfg.Registerer),
+
+		circuits: map[string]*circuit{},
+
+		now: time.Now,
+	}
+}
+
+// WithBreaker is an Option which fails requests fast with cb while the host
+// of the client is failing. The same CircuitBreaker can be given to several
+// clients to share their circuits.
+func WithBreaker(cb *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.Breaker = cb
+	}
+}
+
+// State returns the state of the circuit to host.
+func (cb *CircuitBreaker) State(host string) BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		return BreakerClosed
+	}
+
+	// an open circuit which has cooled down will allow a trial request
+	if c.state == BreakerOpen && !cb.now().Before(c.openUntil) {
+		return BreakerHalfOpen
+	}
+
+	return c.state
+}
+
+// BreakerState returns the state of the circuit to the host of the client, or
+// BreakerClosed if it has no circuit breaker.
+func (c *Client) BreakerState() BreakerState {
+	if c.Breaker == nil {
+		return BreakerClosed
+	}
+
+	return c.Breaker.State(c.Host)
+}
+
+// circuit is the state of requests to a single host.
+type circuit struct {
+	state      BreakerState
+	generation int
+
+	// closed: outcomes of requests within the window
+	buckets     [breakerBuckets]breakerBucket
+	bucketStart time.Time
+
+	// open: when trial requests are allowed
+	openUntil time.Time
+
+	// half-open: trial requests in flight and succeeded
+	trials    int
+	successes int
+}
+
+type breakerBucket struct {
+	requests, failures int
+}
+
+// allow reports whether a request may be sent to host, returning a function
+// to record its outcome, or a circuit_open error if it may not.
+func (cb *CircuitBreaker) allow(ctx context.Context, host string) (func(res *http.Response, err error), error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[host]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[host] = c
+	}
+
+	now := cb.now()
+
+	if c.state == BreakerOpen {
+		if now.Before(c.openUntil) {
+			return nil, cher.New(cher.CircuitOpen, cher.M{
+				"host":        host,
+				"retry_after": int(math.Ceil(c.openUntil.Sub(now).Seconds())),
+			})
+		}
+
+		cb.transition(host, c, BreakerHalfOpen)
+	}
+
+	if c.state == BreakerHalfOpen {
+		if c.trials >= cb.cfg.HalfOpenRequests {
+			return nil, cher.New(cher.CircuitOpen, cher.M{"host": host})
+		}
+
+		c.trials++
+	}
+
+	generation := c.generation
+
+	return func(res *http.Response, err error) {
+		// requests cancelled by the caller say nothing of the host
+		neutral := ctx.Err() != nil
+		failed := !neutral && cb.cfg.IsFailure(res, err)
+
+		cb.mu.Lock()
+		defer cb.mu.Unlock()
+
+		// ignore the outcome of requests allowed before the state changed
+		if c.generation != generation {
+			return
+		}
+
+		cb.record(host, c, neutral, failed)
+	}, nil
+}
+
+func (cb *CircuitBreaker) record(host string, c *circuit, neutral, failed bool) {
+	switch c.state {
+	case BreakerHalfOpen:
+		c.trials--
+
+		switch {
+		case neutral:
+		case failed:
+			cb.open(host, c)
+		default:
+			c.successes++
+
+			if c.successes >= cb.cfg.HalfOpenRequests {
+				cb.transition(host, c, BreakerClosed)
+			}
+		}
+
+	case BreakerClosed:
+		if neutral {
+			return
+		}
+
+		bucket := cb.bucket(c)
+		bucket.requests++
+		if failed {
+			bucket.failures++
+		}
+
+		var requests, failures int
+		for _, b := range c.buckets {
+			requests += b.requests
+			failures += b.failures
+		}
+
+		if failed && requests >= cb.cfg.MinRequests && float64(failures)/float64(requests) >= cb.cfg.FailureRate {
+			cb.open(host, c)
+		}
+	}
+}
+
+// bucket returns the bucket for the current time, expiring buckets which have
+// fallen out of the window.
+func (cb *CircuitBreaker) bucket(c *circuit) *breakerBucket {
+	size := cb.cfg.Window / breakerBuckets
+	if size <= 0 {
+		size = 1
+	}
+
+	now := cb.now()
+	i := int(now.Sub(c.bucketStart) / size)
+
+	switch {
+	case c.bucketStart.IsZero(), i >= 2*breakerBuckets-1:
+		c.buckets = [breakerBuckets]breakerBucket{}
+		c.bucketStart = now
+		i = 0
+
+	case i >= breakerBuckets:
+		// slide the window so the current bucket is the last
+		shift := i - breakerBuckets + 1
+
+		copy(c.buckets[:], c.buckets[shift:])
+		for j := breakerBuckets - shift; j < breakerBuckets; j++ {
+			c.buckets[j] = breakerBucket{}
+		}
+
+		c.bucketStart = c.bucketStart.Add(time.Duration(shift) * size)
+		i = breakerBuckets - 1
+	}
+
+	return &c.buckets[i]
+}
+
+func (cb *CircuitBreaker) open(host string, c *circuit) {
+	c.openUntil = cb.now().Add(cb.cfg.CoolDown)
+	cb.transition(host, c, BreakerOpen)
+}
+
+func (cb *CircuitBreaker) transition(host string, c *circuit, state BreakerState) {
+	cb.metrics.transition(host, c.state, state)
+
+	c.state = state
+	c.generation++
+	c.trials = 0
+	c.successes = 0
+
+	if state == BreakerClosed {
+		c.buckets = [breakerBuckets]breakerBucket{}
+		c.bucketStart = time.Time{}
+	}
+}
+
+func isFailure(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= 500
+}
+
+type breakerMetrics struct {
+	state   *prometheus.GaugeVec
+	changes *prometheus.CounterVec
+}
+
+func newBreakerMetrics(r prometheus.Registerer) *breakerMetrics {
+	if r == nil {
+		return nil
+	}
+
+	m := &breakerMetrics{
+		state: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "jsonclient_circuit_breaker_state",
+				Help: "State of the circuit to each host: 0 closed, 1 open, 2 half-open",
+			},
+			[]string{"host"},
+		),
+		changes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "jsonclient_circuit_breaker_state_changes_total",
+				Help: "Total number of circuit state changes by host and new state",
+			},
+			[]string{"host", "from", "to"},
+		),
+	}
+
+	// reuse metrics already registered by another CircuitBreaker, so one can
+	// be created per client
+	m.state = promutil.RegisterOrExisting(r, m.state).(*prometheus.GaugeVec)
+	m.changes = promutil.RegisterOrExisting(r, m.changes).(*prometheus.CounterVec)
+
+	return m
+}
+
+func (m *breakerMetrics) transition(host string, from, to BreakerState) {
+	if m != nil {
+		m.state.WithLabelValues(host).Set(float64(to))
+		m.changes.WithLabelValues(host, from.String(), to.String()).Inc()
+	}
+}
@@ -0,0 +1,147 @@
+package jsonclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var status int
+	var requests int
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer hs.Close()
+
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+
+	reg := prometheus.NewRegistry()
+
+	breaker := NewCircuitBreaker(BreakerConfig{
+		MinRequests: 4,
+		FailureRate: 0.5,
+		CoolDown:    time.Minute,
+		Registerer:  reg,
+	})
+	breaker.now = func() time.Time { return now }
+
+	client := NewClient(hs.URL+"/", nil)
+	client.Breaker = breaker
+
+	call := func() error {
+		return client.Do(context.Background(), "GET", "test", nil, nil, nil)
+	}
+
+	// two successes and a failure stay below the minimum requests
+	status = http.StatusNoContent
+	require.NoError(t, call())
+	require.NoError(t, call())
+
+	status = http.StatusInternalServerError
+	assert.Error(t, call())
+	assert.Equal(t, BreakerClosed, client.BreakerState())
+
+	// a second failure reaches the failure rate
+	assert.Error(t, call())
+	assert.Equal(t, BreakerOpen, client.BreakerState())
+
+	// requests fail fast while open
+	err := call()
+
+	var cErr cher.E
+	if assert.ErrorAs(t, err, &cErr) {
+		assert.Equal(t, cher.CircuitOpen, cErr.Code)
+		assert.Equal(t, 60, cErr.Meta["retry_after"])
+	}
+
+	assert.Equal(t, 4, requests)
+
+	// after the cool-down, a failed trial opens the circuit again
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, client.BreakerState())
+
+	assert.Error(t, call())
+	assert.Equal(t, 5, requests)
+	assert.Equal(t, BreakerOpen, client.BreakerState())
+
+	// and a successful trial closes it
+	now = now.Add(time.Minute)
+	status = http.StatusNoContent
+
+	require.NoError(t, call())
+	assert.Equal(t, BreakerClosed, client.BreakerState())
+
+	t.Run("Window", func(t *testing.T) {
+		status = http.StatusInternalServerError
+
+		// failures which have left the window are forgotten
+		for i := 0; i < 3; i++ {
+			assert.Error(t, call())
+		}
+
+		now = now.Add(11 * time.Second)
+
+		assert.Error(t, call())
+		assert.Error(t, call())
+
+		assert.Equal(t, BreakerClosed, client.BreakerState())
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+
+		changes := map[string]float64{}
+		for _, mf := range mfs {
+			if mf.GetName() != "jsonclient_circuit_breaker_state_changes_total" {
+				continue
+			}
+
+			for _, m := range mf.GetMetric() {
+				var from, to string
+				for _, l := range m.GetLabel() {
+					switch l.GetName() {
+					case "from":
+						from = l.GetValue()
+					case "to":
+						to = l.GetValue()
+					}
+				}
+
+				changes[from+">"+to] = m.GetCounter().GetValue()
+			}
+		}
+
+		assert.Equal(t, map[string]float64{
+			"closed>open":      1,
+			"open>half_open":   2,
+			"half_open>open":   1,
+			"half_open>closed": 1,
+		}, changes)
+	})
+}
+
+func TestCircuitBreakerCancelled(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConfig{MinRequests: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done, err := breaker.allow(ctx, "example.com")
+	require.NoError(t, err)
+
+	// requests cancelled by the caller are not failures of the host
+	cancel()
+	done(nil, context.Canceled)
+
+	assert.Equal(t, BreakerClosed, breaker.State("example.com"))
+}
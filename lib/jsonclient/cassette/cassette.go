@@ -0,0 +1,375 @@
+// Package cassette records HTTP interactions to fixture files and replays them
+// offline, so clients of third-party APIs can be tested deterministically
+// without network access.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordEnv is the environment variable which, when set to a non-empty value,
+// makes Use record interactions rather than replay them.
+const RecordEnv = "CASSETTE_RECORD"
+
+// Mode is whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeReplay replays recorded interactions, failing any request which
+	// was not recorded, without accessing the network.
+	ModeReplay Mode = iota
+
+	// ModeRecord sends requests to the network and records the
+	// interactions, replacing those recorded before when saved.
+	ModeRecord
+)
+
+// Interaction is a request and the response it received.
+type Interaction struct {
+	Request  Request  `json:"request" yaml:"request"`
+	Response Response `json:"response" yaml:"response"`
+}
+
+// Request is a recorded HTTP request.
+type Request struct {
+	Method       string      `json:"method" yaml:"method"`
+	URL          string      `json:"url" yaml:"url"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	Status       int         `json:"status" yaml:"status"`
+	Header       http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// Base64 is the BodyEncoding of bodies which are not valid UTF-8, such as
+// compressed bodies.
+const Base64 = "base64"
+
+func encodeBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(b), Base64
+}
+
+func decodeBody(body, encoding string) []byte {
+	if encoding == Base64 {
+		// bodies which fail to decode are replayed as empty
+		decoded, _ := base64.StdEncoding.DecodeString(body)
+		return decoded
+	}
+
+	return []byte(body)
+}
+
+// Recorder is an http.RoundTripper which records interactions to, or replays
+// them from, a fixture file. Files ending .yaml or .yml are YAML, and any
+// other file is JSON.
+type Recorder struct {
+	// Transport sends requests while recording. Defaults to
+	// http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	// Redactors are applied to interactions before they are recorded, and
+	// to requests before they are matched to recorded interactions, so
+	// redacted values do not prevent a match.
+	Redactors []Redactor
+
+	path string
+	mode Mode
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	replayed     []bool
+}
+
+// New returns a Recorder for the fixture file at path, redacting the
+// Authorization, Cookie, Set-Cookie and X-Api-Key headers along with any
+// further redactors given. In replay mode, the fixture file is loaded and must
+// exist.
+func New(path string, mode Mode, redactors ...Redactor) (*Recorder, error) {
+	r := &Recorder{
+		Redactors: append([]Redactor{RedactHeaders("Authorization", "Cookie", "Set-Cookie", "X-Api-Key")}, redactors...),
+
+		path: path,
+		mode: mode,
+	}
+
+	if mode == ModeReplay {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// TB is the subset of testing.TB used by Use.
+type TB interface {
+	Helper()
+	Cleanup(func())
+	Fatalf(format string, args ...interface{})
+}
+
+// Use returns a Recorder for the fixture file at path for the duration of a
+// test, replaying interactions unless the RecordEnv environment variable is
+// set, in which case interactions are recorded and saved once the test ends.
+func Use(t TB, path string, redactors ...Redactor) *Recorder {
+	t.Helper()
+
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+
+	r, err := New(path, mode, redactors...)
+	if err != nil {
+		t.Fatalf("cassette: %s", err)
+	}
+
+	if mode == ModeRecord {
+		t.Cleanup(func() {
+			if err := r.Save(); err != nil {
+				t.Fatalf("cassette: %s", err)
+			}
+		})
+	}
+
+	return r
+}
+
+// RoundTrip records or replays an interaction.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	recorded := Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+	}
+
+	recorded.Body, recorded.BodyEncoding = encodeBody(body)
+
+	if r.mode == ModeRecord {
+		return r.record(req, recorded)
+	}
+
+	return r.replay(req, recorded)
+}
+
+func (r *Recorder) record(req *http.Request, recorded Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := &Interaction{
+		Request: recorded,
+		Response: Response{
+			Status: res.StatusCode,
+			Header: res.Header.Clone(),
+		},
+	}
+
+	i.Response.Body, i.Response.BodyEncoding = encodeBody(body)
+
+	r.redact(i)
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, i)
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	i := &Interaction{Request: recorded}
+	r.redact(i)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// interactions are replayed in the order they were recorded, then
+	// repeated once every match has been replayed
+	match := -1
+	for n, candidate := range r.interactions {
+		if !matches(candidate.Request, i.Request) {
+			continue
+		}
+
+		if !r.replayed[n] {
+			match = n
+			break
+		} else if match < 0 {
+			match = n
+		}
+	}
+
+	if match < 0 {
+		return nil, fmt.Errorf("cassette: no interaction recorded in %s for %s %s", r.path, req.Method, req.URL)
+	}
+
+	r.replayed[match] = true
+	res := r.interactions[match].Response
+	body := decodeBody(res.Body, res.BodyEncoding)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.Status, http.StatusText(res.Status)),
+		StatusCode:    res.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        res.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// matches reports whether a request matches a recorded request on its method,
+// path, query and body. JSON bodies match if they are equivalent.
+func matches(recorded, req Request) bool {
+	if recorded.Method != req.Method {
+		return false
+	}
+
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	reqURL, err := url.Parse(req.URL)
+	if err != nil {
+		return false
+	}
+
+	if recordedURL.Host != reqURL.Host || recordedURL.Path != reqURL.Path || !reflect.DeepEqual(recordedURL.Query(), reqURL.Query()) {
+		return false
+	}
+
+	recordedBody, reqBody := decodeBody(recorded.Body, recorded.BodyEncoding), decodeBody(req.Body, req.BodyEncoding)
+	if bytes.Equal(recordedBody, reqBody) {
+		return true
+	}
+
+	var recordedJSON, reqJSON interface{}
+	if json.Unmarshal(recordedBody, &recordedJSON) != nil || json.Unmarshal(reqBody, &reqJSON) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(recordedJSON, reqJSON)
+}
+
+func (r *Recorder) redact(i *Interaction) {
+	for _, redact := range r.Redactors {
+		redact(i)
+	}
+}
+
+// Interactions returns the interactions recorded or loaded for replay.
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to the fixture file, creating its
+// directory if needed. It does nothing in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b []byte
+	var err error
+
+	if isYAML(r.path) {
+		b, err = yaml.Marshal(r.interactions)
+	} else {
+		b, err = json.MarshalIndent(r.interactions, "", "\t")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, b, 0o644)
+}
+
+func (r *Recorder) load() error {
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	if isYAML(r.path) {
+		err = yaml.Unmarshal(b, &r.interactions)
+	} else {
+		err = json.Unmarshal(b, &r.interactions)
+	}
+
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", r.path, err)
+	}
+
+	r.replayed = make([]bool, len(r.interactions))
+
+	return nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	return ext == ".yaml" || ext == ".yml"
+}
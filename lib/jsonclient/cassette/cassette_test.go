@@ -0,0 +1,160 @@
+package cassette
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type vehicle struct {
+	VRM   string `json:"vrm"`
+	Make  string `json:"make"`
+	Owner string `json:"owner,omitempty"`
+}
+
+func TestRecorder(t *testing.T) {
+	var requests int
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"vrm":"CUV001","make":"BMW","owner":"James"}`))
+	}))
+	defer hs.Close()
+
+	newClient := func(rt http.RoundTripper) *jsonclient.Client {
+		c := jsonclient.NewClient(hs.URL+"/", &http.Client{Transport: rt})
+		c.UserAgent = "test"
+
+		return c
+	}
+
+	call := func(c *jsonclient.Client, key string, query url.Values, src interface{}) (*vehicle, error) {
+		var res vehicle
+
+		err := c.DoWithHeaders(context.Background(), "POST", "vehicles", http.Header{"X-Api-Key": []string{key}}, query, src, &res)
+
+		return &res, err
+	}
+
+	for _, ext := range []string{".json", ".yaml"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fixtures", "vehicles"+ext)
+
+			recorder, err := New(path, ModeRecord, RedactQuery("token"), RedactBodyFields("owner", "name"))
+			require.NoError(t, err)
+
+			res, err := call(newClient(recorder), "secret", url.Values{"a": {"1"}, "token": {"abc"}}, map[string]interface{}{"vrm": "CUV001", "driver": map[string]string{"name": "James"}})
+			require.NoError(t, err)
+
+			// the recorded response is passed on unredacted
+			assert.Equal(t, "James", res.Owner)
+
+			require.NoError(t, recorder.Save())
+
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			assert.NotContains(t, string(b), "secret")
+			assert.NotContains(t, string(b), "James")
+			assert.NotContains(t, string(b), "abc")
+			assert.Contains(t, string(b), Redacted)
+
+			before := requests
+
+			replayer, err := New(path, ModeReplay, RedactQuery("token"), RedactBodyFields("owner", "name"))
+			require.NoError(t, err)
+
+			client := newClient(replayer)
+
+			// requests match regardless of query order and JSON formatting,
+			// and of the values of redacted fields
+			res, err = call(client, "other", url.Values{"token": {"xyz"}, "a": {"1"}}, map[string]interface{}{"driver": map[string]string{"name": "Other"}, "vrm": "CUV001"})
+			require.NoError(t, err)
+			assert.Equal(t, &vehicle{VRM: "CUV001", Make: "BMW", Owner: Redacted}, res)
+
+			// interactions can be replayed more than once
+			_, err = call(client, "other", url.Values{"a": {"1"}, "token": {"abc"}}, map[string]interface{}{"vrm": "CUV001", "driver": map[string]string{"name": "James"}})
+			require.NoError(t, err)
+
+			_, err = call(client, "secret", url.Values{"a": {"2"}, "token": {"abc"}}, map[string]interface{}{"vrm": "CUV001", "driver": map[string]string{"name": "James"}})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "cassette: no interaction recorded")
+			}
+
+			_, err = call(client, "secret", url.Values{"a": {"1"}, "token": {"abc"}}, map[string]interface{}{"vrm": "CUV002", "driver": map[string]string{"name": "James"}})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "cassette: no interaction recorded")
+			}
+
+			assert.Equal(t, before, requests)
+		})
+	}
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestReplayOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "order.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"request": {"method": "GET", "url": "https://example.com/status"}, "response": {"status": 503}},
+		{"request": {"method": "GET", "url": "https://example.com/status"}, "response": {"status": 200, "body": "ok"}}
+	]`), 0o644))
+
+	r, err := New(path, ModeReplay)
+	require.NoError(t, err)
+
+	// retries replay the interactions in the order they were recorded
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "https://example.com/status", nil)
+
+		res, err := r.RoundTrip(req)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		statuses = append(statuses, res.StatusCode)
+	}
+
+	assert.Equal(t, []int{503, 200, 503}, statuses)
+}
+
+func TestRedactBodyFields(t *testing.T) {
+	tests := []struct {
+		Name string
+		Body string
+		Want string
+	}{
+		{"Nested", `{"a":{"name":"x"},"b":[{"name":"y"}],"n":1.50}`, `{"a":{"name":"REDACTED"},"b":[{"name":"REDACTED"}],"n":1.50}`},
+		{"Unchanged", `{ "other": 1 }`, `{ "other": 1 }`},
+		{"NotJSON", `name=x`, `name=x`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			i := &Interaction{Request: Request{Body: test.Body}}
+
+			RedactBodyFields("name")(i)
+
+			assert.Equal(t, test.Want, i.Request.Body)
+		})
+	}
+}
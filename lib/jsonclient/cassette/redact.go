@@ -0,0 +1,76 @@
+package cassette
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient/internal/redact"
+)
+
+// Redacted replaces the values removed by redactors.
+const Redacted = redact.Redacted
+
+// Redactor removes secrets and personal data from an interaction before it is
+// recorded or matched.
+type Redactor func(i *Interaction)
+
+// RedactHeaders redacts the values of the named request and response headers.
+func RedactHeaders(names ...string) Redactor {
+	return func(i *Interaction) {
+		for _, name := range names {
+			redactHeader(i.Request.Header, name)
+			redactHeader(i.Response.Header, name)
+		}
+	}
+}
+
+func redactHeader(h http.Header, name string) {
+	values := h.Values(name)
+	for n := range values {
+		values[n] = Redacted
+	}
+}
+
+// RedactQuery redacts the values of the named request query parameters, such
+// as API keys.
+func RedactQuery(params ...string) Redactor {
+	return func(i *Interaction) {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return
+		}
+
+		query := u.Query()
+
+		var redacted bool
+		for _, param := range params {
+			values := query[param]
+			for n := range values {
+				values[n] = Redacted
+				redacted = true
+			}
+		}
+
+		if redacted {
+			u.RawQuery = query.Encode()
+			i.Request.URL = u.String()
+		}
+	}
+}
+
+// RedactBodyFields redacts the values of the named fields, at any depth, in
+// JSON request and response bodies, such as names, addresses and dates of
+// birth.
+func RedactBodyFields(fields ...string) Redactor {
+	set := redact.Fields(fields)
+
+	return func(i *Interaction) {
+		if i.Request.BodyEncoding == "" {
+			i.Request.Body = string(redact.JSON([]byte(i.Request.Body), set))
+		}
+
+		if i.Response.BodyEncoding == "" {
+			i.Response.Body = string(redact.JSON([]byte(i.Response.Body), set))
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package jsonclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/clog"
+	"github.com/cuvva/cuvva-public-go/lib/internal/promutil"
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient/internal/redact"
+	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// maxLoggedBody is the longest body, in bytes, logged in debug mode.
+const maxLoggedBody = 4096
+
+// InstrumentConfig configures an InstrumentedTransport.
+type InstrumentConfig struct {
+	// Registerer records the duration and status of outbound requests, if
+	// set.
+	Registerer prometheus.Registerer
+
+	// PathTemplates are the paths requested, such as
+	// "/postcodes/{postcode}", used to label metrics and logs without a label
+	// for every path. Segments of paths which match no template are replaced
+	// with {id} if they contain a digit.
+	PathTemplates []string
+
+	// Debug logs request and response bodies, with the values of
+	// RedactFields redacted. Responses are read in full before being
+	// returned.
+	Debug bool
+
+	// RedactFields are the fields, at any depth, of JSON bodies whose values
+	// are redacted in debug logs, such as names and dates of birth.
+	RedactFields []string
+}
+
+// InstrumentedTransport records metrics and logs for each request before
+// handing it to the embedded transport for execution. Requests are logged to
+// the clog logger in their context.
+type InstrumentedTransport struct {
+	http.RoundTripper
+
+	cfg          InstrumentConfig
+	redactFields map[string]bool
+	templates    [][]string
+	metrics      *transportMetrics
+}
+
+// NewInstrumentedTransport returns a new InstrumentedTransport that will
+// record metrics and logs for each request performed by rt.
+func NewInstrumentedTransport(rt http.RoundTripper, cfg InstrumentConfig) *InstrumentedTransport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	templates := make([][]string, len(cfg.PathTemplates))
+	for i, template := range cfg.PathTemplates {
+		templates[i] = strings.Split(strings.Trim(template, "/"), "/")
+	}
+
+	return &InstrumentedTransport{
+		RoundTripper: rt,
+
+		cfg:          cfg,
+		redactFields: redact.Fields(cfg.RedactFields),
+		templates:    templates,
+		metrics:      newTransportMetrics(cfg.Registerer),
+	}
+}
+
+// WithInstrumentation is an Option which instruments the client, as
+// Instrument.
+func WithInstrumentation(cfg InstrumentConfig) Option {
+	return func(c *Client) {
+		c.Instrument(cfg)
+	}
+}
+
+// Instrument wraps the transport of the client with an InstrumentedTransport.
+// The HTTP client is copied first, so a client shared with others, such as
+// http.DefaultClient, is not changed.
+func (c *Client) Instrument(cfg InstrumentConfig) {
+	httpClient := http.DefaultClient
+	if c.Client != nil {
+		httpClient = c.Client
+	}
+
+	instrumented := *httpClient
+	instrumented.Transport = NewInstrumentedTransport(httpClient.Transport, cfg)
+
+	c.Client = &instrumented
+}
+
+// RoundTrip records metrics and logs for the request.
+func (it *InstrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if it.cfg.Debug {
+		requestBody = readRequestBody(req)
+	}
+
+	start := time.Now()
+	res, err := it.RoundTripper.RoundTrip(req)
+	duration := time.Since(start)
+
+	path := it.templatePath(req.URL.Path)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+
+	it.metrics.observe(req.URL.Host, req.Method, path, status, duration)
+
+	requestID := req.Header.Get("Request-Id")
+	if requestID == "" {
+		requestID = request.GetRequestIDContext(req.Context())
+	}
+
+	entry := clog.Get(req.Context()).WithFields(logrus.Fields{
+		"request_id":           requestID,
+		"outbound_host":        req.URL.Host,
+		"outbound_method":      req.Method,
+		"outbound_path":        path,
+		"outbound_status":      status,
+		"outbound_duration":    duration.String(),
+		"outbound_duration_us": int64(duration / time.Microsecond),
+	})
+
+	if it.cfg.Debug {
+		entry = entry.WithField("outbound_request_body", it.redact(requestBody))
+
+		if err == nil {
+			var responseBody []byte
+			if responseBody, err = io.ReadAll(res.Body); err == nil {
+				res.Body.Close()
+				res.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+				entry = entry.WithField("outbound_response_body", it.redact(responseBody))
+			} else {
+				res.Body.Close()
+				res = nil
+			}
+		}
+	}
+
+	switch {
+	case err != nil:
+		entry.WithError(err).Warn("outbound request failed")
+	case res.StatusCode >= 500:
+		entry.Warn("outbound request")
+	default:
+		entry.Info("outbound request")
+	}
+
+	return res, err
+}
+
+// templatePath returns the first template matching path, or path with the
+// segments which contain a digit replaced.
+func (it *InstrumentedTransport) templatePath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, template := range it.templates {
+		if matchesTemplate(template, segments) {
+			return it.cfg.PathTemplates[i]
+		}
+	}
+
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, "0123456789") {
+			segments[i] = "{id}"
+		}
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+func matchesTemplate(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+
+	for i, part := range template {
+		if !strings.HasPrefix(part, "{") && part != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readRequestBody returns the body of req, leaving it to be read again.
+func readRequestBody(req *http.Request) []byte {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			defer body.Close()
+
+			b, _ := io.ReadAll(body)
+			return b
+		}
+	}
+
+	b, _ := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(b))
+
+	return b
+}
+
+// redact returns a body for debug logs, with the values of the redacted
+// fields of JSON bodies replaced and long bodies truncated.
+func (it *InstrumentedTransport) redact(body []byte) string {
+	if len(it.redactFields) > 0 {
+		body = redact.JSON(body, it.redactFields)
+	}
+
+	if len(body) > maxLoggedBody {
+		return string(body[:maxLoggedBody]) + "..."
+	}
+
+	return string(body)
+}
+
+type transportMetrics struct {
+	duration *prometheus.HistogramVec
+	total    *prometheus.CounterVec
+}
+
+func newTransportMetrics(r prometheus.Registerer) *transportMetrics {
+	if r == nil {
+		return nil
+	}
+
+	labels := []string{"host", "method", "path", "status"}
+
+	m := &transportMetrics{
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "jsonclient_request_duration_seconds",
+				Help:    "Duration of an outbound HTTP request in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			labels,
+		),
+		total: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "jsonclient_request_total",
+				Help: "Total number of outbound HTTP requests",
+			},
+			labels,
+		),
+	}
+
+	// reuse metrics already registered by another InstrumentedTransport, so
+	// each client can be instrumented
+	m.duration = promutil.RegisterOrExisting(r, m.duration).(*prometheus.HistogramVec)
+	m.total = promutil.RegisterOrExisting(r, m.total).(*prometheus.CounterVec)
+
+	return m
+}
+
+func (m *transportMetrics) observe(host, method, path, status string, duration time.Duration) {
+	if m != nil {
+		m.duration.WithLabelValues(host, method, path, status).Observe(duration.Seconds())
+		m.total.WithLabelValues(host, method, path, status).Inc()
+	}
+}
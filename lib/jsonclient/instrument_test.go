@@ -0,0 +1,125 @@
+package jsonclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/clog"
+	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrument(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/vehicles/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"vrm":"CUV001","owner":{"name":"James"}}`))
+	}))
+	defer hs.Close()
+
+	reg := prometheus.NewRegistry()
+
+	client := NewClient(hs.URL+"/", nil)
+	original := client.Client
+
+	client.Instrument(InstrumentConfig{
+		Registerer:    reg,
+		PathTemplates: []string{"/vehicles/{vrm}/tests"},
+		Debug:         true,
+		RedactFields:  []string{"name"},
+	})
+
+	// the original HTTP client is left unchanged
+	assert.NotSame(t, original, client.Client)
+	assert.Nil(t, original.Transport)
+
+	logger, hook := test.NewNullLogger()
+
+	ctx := clog.Set(context.Background(), logrus.NewEntry(logger))
+	ctx = request.SetRequestIDContext(ctx, "req_1")
+
+	var res map[string]interface{}
+	require.NoError(t, client.Do(ctx, "POST", "vehicles/CUV001/tests", nil, map[string]string{"name": "James", "vrm": "CUV001"}, &res))
+
+	// the response body is still returned after being logged
+	assert.Equal(t, "CUV001", res["vrm"])
+
+	require.NoError(t, client.Do(ctx, "GET", "vehicles/AB12CDE", nil, nil, nil))
+	assert.Error(t, client.Do(ctx, "GET", "vehicles/fail", nil, nil, nil))
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, logrus.InfoLevel, entries[0].Level)
+	assert.Equal(t, "req_1", entries[0].Data["request_id"])
+	assert.Equal(t, "POST", entries[0].Data["outbound_method"])
+	assert.Equal(t, "/vehicles/{vrm}/tests", entries[0].Data["outbound_path"])
+	assert.Equal(t, "200", entries[0].Data["outbound_status"])
+	assert.JSONEq(t, `{"name":"REDACTED","vrm":"CUV001"}`, entries[0].Data["outbound_request_body"].(string))
+	assert.JSONEq(t, `{"vrm":"CUV001","owner":{"name":"REDACTED"}}`, entries[0].Data["outbound_response_body"].(string))
+
+	assert.Equal(t, "/vehicles/{id}", entries[1].Data["outbound_path"])
+
+	assert.Equal(t, logrus.WarnLevel, entries[2].Level)
+	assert.Equal(t, "502", entries[2].Data["outbound_status"])
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+
+	totals := map[string]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != "jsonclient_request_total" {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+
+			assert.Equal(t, hs.Listener.Addr().String(), labels["host"])
+			totals[labels["method"]+" "+labels["path"]+" "+labels["status"]] = m.GetCounter().GetValue()
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		"POST /vehicles/{vrm}/tests 200": 1,
+		"GET /vehicles/{id} 200":         1,
+		"GET /vehicles/fail 502":         1,
+	}, totals)
+
+	t.Run("TransportError", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		client := NewClient(closed.URL+"/", nil)
+		client.Instrument(InstrumentConfig{})
+
+		hook.Reset()
+
+		assert.Error(t, client.Do(ctx, "GET", "vehicles", nil, nil, nil))
+
+		if entry := hook.LastEntry(); assert.NotNil(t, entry) {
+			assert.Equal(t, "error", entry.Data["outbound_status"])
+			assert.Equal(t, "outbound request failed", entry.Message)
+		}
+	})
+}
+
+func TestWithInstrumentation(t *testing.T) {
+	client := NewClient("http://localhost/", nil, WithInstrumentation(InstrumentConfig{
+		Registerer: prometheus.NewRegistry(),
+	}))
+
+	assert.IsType(t, &InstrumentedTransport{}, client.Client.Transport)
+}
@@ -0,0 +1,70 @@
+// Package redact removes the values of named fields from JSON bodies, for the
+// debug logs of instrumented clients and the interactions recorded by
+// cassettes.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Redacted replaces the values of redacted fields.
+const Redacted = "REDACTED"
+
+// Fields returns the set of field names to redact.
+func Fields(fields []string) map[string]bool {
+	set := map[string]bool{}
+	for _, field := range fields {
+		set[field] = true
+	}
+
+	return set
+}
+
+// JSON returns body with the values of the fields, at any depth, redacted, or
+// body unchanged if it is not JSON or contains none of the fields.
+func JSON(body []byte, fields map[string]bool) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+
+	if !value(v, fields) {
+		return body
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+
+	return b
+}
+
+func value(v interface{}, fields map[string]bool) bool {
+	var redacted bool
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if fields[key] {
+				v[key] = Redacted
+				redacted = true
+			} else if value(val, fields) {
+				redacted = true
+			}
+		}
+
+	case []interface{}:
+		for _, val := range v {
+			if value(val, fields) {
+				redacted = true
+			}
+		}
+	}
+
+	return redacted
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/httpcompress"
 	"github.com/cuvva/cuvva-public-go/lib/middleware/request"
 	"github.com/cuvva/cuvva-public-go/lib/version"
 )
@@ -22,8 +24,40 @@ var (
 	// ErrNoResponse is returned when a client request is given a body to
 	// unmarshal to however the server does not return any content (HTTP 204).
 	ErrNoResponse = &ClientRequestError{"no response to unmarshal to body", nil}
+
+	// ErrResponseTooLarge is returned when a compressed response body
+	// decompresses to more than the configured maximum size.
+	ErrResponseTooLarge = errors.New("response body exceeds maximum decompressed size")
+)
+
+const (
+	// DefaultCompressionMinSize is the default smallest request body, in
+	// bytes, which is compressed.
+	DefaultCompressionMinSize = 1024
+
+	// DefaultMaxDecompressedSize is the default largest size, in bytes, a
+	// compressed response body may decompress to.
+	DefaultMaxDecompressedSize = 10 << 20
 )
 
+// CompressionConfig configures the compression of request and response
+// bodies with gzip or zstd.
+type CompressionConfig struct {
+	// RequestEncoding is the content coding used to compress request bodies,
+	// either httpcompress.Gzip or httpcompress.Zstd. If empty, request bodies
+	// are not compressed. The server must support the content coding.
+	RequestEncoding string
+
+	// MinSize is the smallest request body, in bytes, which is compressed.
+	// Defaults to DefaultCompressionMinSize if zero.
+	MinSize int
+
+	// MaxDecompressedSize is the largest size, in bytes, a compressed
+	// response body may decompress to, guarding against zip bombs. Defaults
+	// to DefaultMaxDecompressedSize if zero.
+	MaxDecompressedSize int64
+}
+
 // DefaultUserAgent is the default HTTP User-Agent Header that is presented to the server.
 var DefaultUserAgent = "jsonclient/" + version.Truncated + " (+https://cuvva.com)"
 
@@ -35,12 +69,34 @@ type Client struct {
 
 	UserAgent string
 
+	// Compression enables gzip and zstd compression of request and response
+	// bodies. It is disabled when nil.
+	Compression *CompressionConfig
+
+	// Retry retries requests which fail in a way which may succeed if
+	// retried. Requests are attempted once when nil.
+	Retry *RetryPolicy
+
+	// Breaker fails requests fast while the host is failing, rather than
+	// sending them. Requests are always sent when nil.
+	Breaker *CircuitBreaker
+
+	// MaxResponseSize is the largest response body, in bytes, which is
+	// decoded, failing larger responses with a response_too_large error
+	// rather than exhausting memory. Unlimited if zero.
+	MaxResponseSize int64
+
 	Client *http.Client
 }
 
+// Option configures a Client as it is created, including by the constructors
+// of the clients built on Client, such as WithInstrumentation.
+type Option func(c *Client)
+
 // NewClient returns a client configured with a transport scheme, remote host
-// and URL prefix supplied as a URL <scheme>://<host></prefix>
-func NewClient(baseURL string, c *http.Client) *Client {
+// and URL prefix supplied as a URL <scheme>://<host></prefix>, and then by
+// any options given.
+func NewClient(baseURL string, c *http.Client, opts ...Option) *Client {
 	remote, err := url.Parse(baseURL)
 	if err != nil {
 		panic(err)
@@ -52,7 +108,7 @@ func NewClient(baseURL string, c *http.Client) *Client {
 		}
 	}
 
-	return &Client{
+	client := &Client{
 		Scheme: remote.Scheme,
 		Host:   remote.Host,
 		Prefix: remote.Path,
@@ -61,6 +117,12 @@ func NewClient(baseURL string, c *http.Client) *Client {
 
 		Client: c,
 	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
 }
 
 // Do executes an HTTP request against the configured server.
@@ -70,6 +132,38 @@ func (c *Client) Do(ctx context.Context, method, path string, params url.Values,
 
 // DoWithHeaders executes an HTTP request against the configured server with custom headers.
 func (c *Client) DoWithHeaders(ctx context.Context, method, path string, headers http.Header, params url.Values, src, dst interface{}, requestModifiers ...func(r *http.Request)) error {
+	res, attempts, err := c.do(ctx, method, path, headers, params, src, requestModifiers...)
+	if err != nil {
+		return withAttempts(err, attempts)
+	}
+
+	defer res.Body.Close()
+
+	return withAttempts(c.handleResponse(res, method, path, dst), attempts)
+}
+
+// DoRaw executes an HTTP request against the configured server, returning the
+// response for the caller to consume. Unsuccessful responses are returned as
+// errors in the same way as Do. The caller must close the response body.
+func (c *Client) DoRaw(ctx context.Context, method, path string, headers http.Header, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*http.Response, error) {
+	res, attempts, err := c.do(ctx, method, path, headers, params, src, requestModifiers...)
+	if err != nil {
+		return nil, withAttempts(err, attempts)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		defer res.Body.Close()
+
+		return nil, withAttempts(c.handleResponse(res, method, path, nil), attempts)
+	}
+
+	return res, nil
+}
+
+// do executes a request, retrying it under the retry policy if configured,
+// and returns the response with the number of attempts made, or zero if there
+// is no retry policy.
+func (c *Client) do(ctx context.Context, method, path string, headers http.Header, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*http.Response, int, error) {
 	if c.Client == nil {
 		c.Client = http.DefaultClient
 	}
@@ -100,6 +194,10 @@ func (c *Client) DoWithHeaders(ctx context.Context, method, path string, headers
 		req.URL.RawQuery = params.Encode()
 	}
 
+	if c.Compression != nil {
+		req.Header.Set("Accept-Encoding", httpcompress.AcceptEncoding)
+	}
+
 	for key, value := range headers {
 		req.Header[key] = value
 	}
@@ -110,25 +208,62 @@ func (c *Client) DoWithHeaders(ctx context.Context, method, path string, headers
 
 	err := c.setRequestBody(req, src)
 	if err != nil {
-		return &ClientRequestError{"could not marshal", err}
+		return nil, 0, &ClientRequestError{"could not marshal", err}
+	}
+
+	req = req.WithContext(ctx)
+
+	send := func(req *http.Request) (*http.Response, error) {
+		return c.send(req, method, path)
+	}
+
+	if c.Retry == nil {
+		res, err := send(req)
+		return res, 0, err
 	}
 
-	res, err := c.Client.Do(req.WithContext(ctx))
+	return c.Retry.do(ctx, req, send)
+}
+
+// send makes a single attempt of a request, unless the circuit to the host is
+// open.
+func (c *Client) send(req *http.Request, method, path string) (*http.Response, error) {
+	if c.Breaker != nil {
+		done, err := c.Breaker.allow(req.Context(), c.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := c.roundTrip(req, method, path)
+		done(res, err)
+
+		return res, err
+	}
+
+	return c.roundTrip(req, method, path)
+}
+
+func (c *Client) roundTrip(req *http.Request, method, path string) (*http.Response, error) {
+	res, err := c.Client.Do(req)
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok {
 			if netErr.Timeout() {
-				return cher.New(cher.RequestTimeout, cher.M{"method": method, "path": fullPath, "host": c.Host, "scheme": c.Scheme, "timeout_error": netErr})
+				return nil, cher.New(cher.RequestTimeout, cher.M{"method": method, "path": req.URL.Path, "host": c.Host, "scheme": c.Scheme, "timeout_error": netErr})
 			}
 
-			return &ClientTransportError{method, path, "request failed", netErr}
+			return nil, &ClientTransportError{Method: method, Path: path, ErrorString: "request failed", cause: netErr}
 		}
 
-		return &ClientTransportError{method, path, "unknown error", err}
+		return nil, &ClientTransportError{Method: method, Path: path, ErrorString: "unknown error", cause: err}
 	}
 
-	defer res.Body.Close()
+	if err := c.decompressResponse(res); err != nil {
+		res.Body.Close()
+
+		return nil, &ClientTransportError{Method: method, Path: path, ErrorString: "could not decompress response", cause: err}
+	}
 
-	return c.handleResponse(res, method, path, dst)
+	return res, nil
 }
 
 func (c *Client) setRequestBody(req *http.Request, src interface{}) error {
@@ -140,8 +275,17 @@ func (c *Client) setRequestBody(req *http.Request, src interface{}) error {
 			return err
 		}
 
-		req.Body = io.NopCloser(&buf)
-		req.ContentLength = int64(buf.Len())
+		if err := c.compressRequestBody(req, &buf); err != nil {
+			return err
+		}
+
+		body := buf.Bytes()
+
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.ContentLength = int64(len(body))
 
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	}
@@ -149,7 +293,82 @@ func (c *Client) setRequestBody(req *http.Request, src interface{}) error {
 	return nil
 }
 
+// compressRequestBody compresses buf in place if compression is enabled and
+// it has reached the minimum size.
+func (c *Client) compressRequestBody(req *http.Request, buf *bytes.Buffer) error {
+	if c.Compression == nil || c.Compression.RequestEncoding == "" {
+		return nil
+	}
+
+	minSize := c.Compression.MinSize
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+
+	if buf.Len() < minSize {
+		return nil
+	}
+
+	compressed, err := httpcompress.Compress(c.Compression.RequestEncoding, buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	buf.Reset()
+	buf.Write(compressed)
+
+	req.Header.Set("Content-Encoding", c.Compression.RequestEncoding)
+
+	return nil
+}
+
+// decompressResponse replaces the body of a compressed response with its
+// decompressed body, limited to the maximum decompressed size.
+func (c *Client) decompressResponse(res *http.Response) error {
+	encoding := res.Header.Get("Content-Encoding")
+	if c.Compression == nil || encoding == "" || encoding == httpcompress.Identity {
+		return nil
+	}
+
+	maxSize := c.Compression.MaxDecompressedSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDecompressedSize
+	}
+
+	body, err := httpcompress.NewReader(encoding, res.Body, maxSize)
+	if err != nil {
+		return err
+	}
+
+	res.Body = &decompressedBody{
+		ReadCloser: httpcompress.LimitReader(body, maxSize, ErrResponseTooLarge),
+		raw:        res.Body,
+	}
+
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return nil
+}
+
+// decompressedBody closes both the decompressor and the raw response body.
+type decompressedBody struct {
+	io.ReadCloser
+
+	raw io.ReadCloser
+}
+
+func (db *decompressedBody) Close() error {
+	db.ReadCloser.Close()
+
+	return db.raw.Close()
+}
+
 func (c *Client) handleResponse(res *http.Response, method, path string, dst interface{}) error {
+	body := c.limitBody(res.Body)
+
 	if res.StatusCode >= 200 && res.StatusCode < 300 {
 		if dst == nil {
 			return nil
@@ -159,24 +378,28 @@ func (c *Client) handleResponse(res *http.Response, method, path string, dst int
 			return ErrNoResponse
 		}
 
-		err := json.NewDecoder(res.Body).Decode(dst)
+		err := json.NewDecoder(body).Decode(dst)
 		if err == io.EOF {
 			return ErrNoResponse
+		} else if tooLarge := c.tooLarge(err, method, path); tooLarge != nil {
+			return tooLarge
 		} else if err != nil {
-			return &ClientTransportError{method, path, "could not unmarshal", err}
+			return &ClientTransportError{Method: method, Path: path, ErrorString: "could not unmarshal", cause: err}
 		}
 
 		return nil
 	}
 
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return &ClientTransportError{method, path, "could not read response body stream", err}
+	resBody, err := io.ReadAll(body)
+	if tooLarge := c.tooLarge(err, method, path); tooLarge != nil {
+		return tooLarge
+	} else if err != nil {
+		return &ClientTransportError{Method: method, Path: path, ErrorString: "could not read response body stream", cause: err}
 	}
 
-	var body cher.E
-	if err := json.Unmarshal(resBody, &body); err == nil && body.Code != "" {
-		return body
+	var cErr cher.E
+	if err := json.Unmarshal(resBody, &cErr); err == nil && cErr.Code != "" {
+		return cErr
 	}
 
 	var errorResBody interface{}
@@ -231,6 +454,10 @@ func (cre *ClientRequestError) Error() string {
 type ClientTransportError struct {
 	Method, Path, ErrorString string
 
+	// Attempts is the number of attempts made of the request, if it was
+	// sent under a retry policy.
+	Attempts int
+
 	cause error
 }
 
@@ -239,6 +466,11 @@ func (cte *ClientTransportError) Cause() error {
 	return cte.cause
 }
 
+// Unwrap returns the causal error (if wrapped) or nil
+func (cte *ClientTransportError) Unwrap() error {
+	return cte.cause
+}
+
 func (cte *ClientTransportError) Error() string {
 	if cte.cause != nil {
 		return fmt.Sprintf("%s %s %s: %s", cte.Method, cte.Path, cte.ErrorString, cte.cause.Error())
@@ -2,9 +2,13 @@ package jsonclient
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/stretchr/testify/assert"
@@ -187,3 +191,201 @@ func TestErrorCatching(t *testing.T) {
 	assert.Equal(t, "internal_server_error", err.(cher.E).Code)
 	assert.True(t, gock.IsDone())
 }
+
+func TestDoRaw(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://coo.va/").
+		Get("/test").
+		MatchHeader("Accept", "text/plain").
+		Reply(http.StatusOK).
+		BodyString("raw body")
+
+	gock.New("http://coo.va/").
+		Get("/missing").
+		Reply(http.StatusNotFound).
+		JSON(map[string]string{"code": "not_found"})
+
+	client := NewClient("http://coo.va/", nil)
+	gock.InterceptClient(client.Client)
+
+	res, err := client.DoRaw(context.Background(), "GET", "test", http.Header{"Accept": []string{"text/plain"}}, nil, nil)
+	if assert.NoError(t, err) {
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "raw body", string(body))
+	}
+
+	_, err = client.DoRaw(context.Background(), "GET", "missing", nil, nil, nil)
+	assert.Equal(t, cher.New(cher.NotFound, nil), err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Responses []int
+		Code      string
+
+		Attempts int
+		Error    string
+	}{
+		{"Success", []int{http.StatusOK}, "", 1, ""},
+		{"Unavailable", []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, "", 3, ""},
+		{"TooManyRequests", []int{http.StatusTooManyRequests, http.StatusOK}, "", 2, ""},
+		{"Exhausted", []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout}, "", 3, "gateway_timeout"},
+		{"RequestTimeout", []int{http.StatusInternalServerError, http.StatusOK}, cher.RequestTimeout, 2, ""},
+		{"InternalServerError", []int{http.StatusInternalServerError}, cher.Unknown, 1, cher.Unknown},
+		{"BadRequest", []int{http.StatusBadRequest}, cher.BadRequest, 1, cher.BadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var attempts int
+			var bodies []string
+
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+
+				status := test.Responses[attempts]
+				attempts++
+
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					_, _ = w.Write([]byte(`{"testing":true}`))
+				} else if test.Code != "" {
+					_ = json.NewEncoder(w).Encode(cher.New(test.Code, nil))
+				}
+			}))
+			defer hs.Close()
+
+			client := NewClient(hs.URL+"/", nil)
+			client.Retry = &RetryPolicy{BaseDelay: time.Millisecond}
+
+			var res map[string]bool
+			err := client.Do(context.Background(), "POST", "test", nil, map[string]string{"name": "james"}, &res)
+
+			assert.Equal(t, test.Attempts, attempts)
+
+			// the request body is replayed on every attempt
+			for _, body := range bodies {
+				assert.JSONEq(t, `{"name":"james"}`, body)
+			}
+
+			if test.Error == "" {
+				assert.NoError(t, err)
+				assert.True(t, res["testing"])
+				return
+			}
+
+			var cErr cher.E
+			if assert.ErrorAs(t, err, &cErr) {
+				assert.Equal(t, test.Error, cErr.Code)
+				assert.Equal(t, test.Attempts, cErr.Meta["attempts"])
+			}
+		})
+	}
+
+	t.Run("TransportError", func(t *testing.T) {
+		hs := httptest.NewServer(http.NotFoundHandler())
+		hs.Close()
+
+		client := NewClient(hs.URL+"/", nil)
+		client.Retry = &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+		err := client.Do(context.Background(), "GET", "test", nil, nil, nil)
+
+		var cte *ClientTransportError
+		if assert.ErrorAs(t, err, &cte) {
+			assert.Equal(t, 2, cte.Attempts)
+		}
+	})
+
+	t.Run("RetryAfterDeadline", func(t *testing.T) {
+		var attempts int
+
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer hs.Close()
+
+		client := NewClient(hs.URL+"/", nil)
+		client.Retry = &RetryPolicy{MaxDelay: time.Minute}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// waiting 10 seconds would pass the deadline, so the last response
+		// is returned without waiting
+		start := time.Now()
+		err := client.Do(ctx, "GET", "test", nil, nil, nil)
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, 1, attempts)
+
+		var cErr cher.E
+		if assert.ErrorAs(t, err, &cErr) {
+			assert.Equal(t, "too_many_requests", cErr.Code)
+		}
+	})
+
+	t.Run("RetryAfterExceedsMaxDelay", func(t *testing.T) {
+		var attempts int
+
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer hs.Close()
+
+		client := NewClient(hs.URL+"/", nil)
+		client.Retry = &RetryPolicy{}
+
+		// without a deadline, waiting an hour would block the caller, so the
+		// response is returned without waiting
+		start := time.Now()
+		err := client.Do(context.Background(), "GET", "test", nil, nil, nil)
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, 1, attempts)
+
+		var cErr cher.E
+		if assert.ErrorAs(t, err, &cErr) {
+			assert.Equal(t, "service_unavailable", cErr.Code)
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Value string
+		Delay time.Duration
+		OK    bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"Thu, 02 May 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Thu, 02 May 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Value, func(t *testing.T) {
+			delay, ok := parseRetryAfter(test.Value, now)
+
+			assert.Equal(t, test.OK, ok)
+			assert.Equal(t, test.Delay, delay)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package jsonclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of attempts made of a request,
+	// including the first.
+	DefaultMaxAttempts = 3
+
+	// DefaultRetryBaseDelay is the default delay before the first retry.
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+
+	// DefaultRetryMaxDelay is the default longest delay between attempts.
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+// RetryPolicy configures retries of failed requests, with exponential backoff
+// and jitter between attempts. Requests are retried whatever their HTTP
+// method, so the server must handle repeated requests safely, such as with an
+// Idempotency-Key header.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts made of a request, including the
+	// first. Defaults to DefaultMaxAttempts if zero.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, which doubles with each
+	// further retry. Defaults to DefaultRetryBaseDelay if zero.
+	BaseDelay time.Duration
+
+	// MaxDelay is the longest delay between attempts. Responses with a
+	// Retry-After header asking for a longer delay are not retried. Defaults
+	// to DefaultRetryMaxDelay if zero.
+	MaxDelay time.Duration
+
+	// Retryable reports whether an attempt which returned the response or
+	// transport error should be retried. Defaults to IsRetryable if nil.
+	Retryable func(res *http.Response, err error) bool
+}
+
+// IsRetryable reports whether an attempt failed in a way which may succeed if
+// retried: a transport error, a 429, 502, 503 or 504 response, or a
+// request_timeout error.
+func IsRetryable(res *http.Response, err error) bool {
+	if err != nil {
+		var cErr cher.E
+		if errors.As(err, &cErr) {
+			return cErr.Code == cher.RequestTimeout
+		}
+
+		var cte *ClientTransportError
+		return errors.As(err, &cte)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+
+	case http.StatusInternalServerError:
+		// the body is restored for the response to be handled if the
+		// request is not retried
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err != nil {
+			return false
+		}
+
+		var cErr cher.E
+		return json.Unmarshal(body, &cErr) == nil && cErr.Code == cher.RequestTimeout
+	}
+
+	return false
+}
+
+// do sends req until it succeeds, it fails in a way which should not be
+// retried, or the attempts are exhausted, returning the result of the last
+// attempt and the number of attempts made.
+func (p *RetryPolicy) do(ctx context.Context, req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, int, error) {
+	// requests with a body which cannot be replayed are only sent once
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, attempt - 1, &ClientRequestError{"could not replay body", err}
+			}
+
+			req.Body = body
+		}
+
+		res, err := send(req)
+		if !replayable || attempt >= p.maxAttempts() || ctx.Err() != nil || !p.retryable(res, err) {
+			return res, attempt, err
+		}
+
+		delay := p.backoff(attempt)
+		if res != nil {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				// the server will not be ready within the longest delay, so
+				// return its response rather than block the caller
+				if retryAfter > p.maxDelay() {
+					return res, attempt, err
+				}
+
+				delay = retryAfter
+			}
+		}
+
+		// give up early rather than be cut off by the deadline while waiting
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return res, attempt, err
+		}
+
+		if res != nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, attempt, &ClientTransportError{Method: req.Method, Path: req.URL.Path, ErrorString: "cancelled awaiting retry", cause: ctx.Err()}
+
+		case <-timer.C:
+		}
+	}
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return DefaultRetryMaxDelay
+	}
+
+	return p.MaxDelay
+}
+
+func (p *RetryPolicy) retryable(res *http.Response, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(res, err)
+	}
+
+	return IsRetryable(res, err)
+}
+
+// backoff returns the delay after an attempt, doubling from the base delay up
+// to the max delay, with jitter of up to half the delay so clients which
+// failed together do not retry together.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base, maxDelay := p.BaseDelay, p.maxDelay()
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+
+	delay := maxDelay
+	if attempt < 32 && base<<(attempt-1) < maxDelay {
+		delay = base << (attempt - 1)
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, given
+// either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if delay := at.Sub(now); delay > 0 {
+		return delay, true
+	}
+
+	return 0, true
+}
+
+// withAttempts records the number of attempts made of a request in err, if
+// it was retried under a retry policy.
+func withAttempts(err error, attempts int) error {
+	if err == nil || attempts == 0 {
+		return err
+	}
+
+	switch e := err.(type) {
+	case cher.E:
+		meta := cher.M{}
+		for k, v := range e.Meta {
+			meta[k] = v
+		}
+
+		meta["attempts"] = attempts
+		e.Meta = meta
+
+		return e
+
+	case *ClientTransportError:
+		e.Attempts = attempts
+	}
+
+	return err
+}
@@ -0,0 +1,203 @@
+package jsonclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+)
+
+// ResponseTooLarge is the error code returned when a response body exceeds
+// the maximum response size of the client.
+const ResponseTooLarge = "response_too_large"
+
+// errBodyTooLarge is returned by a limitedBody which has exceeded its limit.
+var errBodyTooLarge = errors.New("response body too large")
+
+// limitedBody fails reads once max bytes have been read from base. Streams move
+// base to the end of each element, limiting the size of elements rather than
+// the whole body.
+type limitedBody struct {
+	io.ReadCloser
+
+	read, base, max int64
+}
+
+func (c *Client) limitBody(body io.ReadCloser) *limitedBody {
+	if c.MaxResponseSize <= 0 {
+		return &limitedBody{ReadCloser: body, max: -1}
+	}
+
+	return &limitedBody{ReadCloser: body, max: c.MaxResponseSize}
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.max < 0 {
+		return lb.ReadCloser.Read(p)
+	}
+
+	remaining := lb.base + lb.max - lb.read
+	if remaining <= 0 {
+		return 0, errBodyTooLarge
+	}
+
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := lb.ReadCloser.Read(p)
+	lb.read += int64(n)
+
+	return n, err
+}
+
+// tooLarge returns a response_too_large error if err was caused by the body
+// exceeding the maximum response size, or nil otherwise.
+func (c *Client) tooLarge(err error, method, path string) error {
+	if !errors.Is(err, errBodyTooLarge) {
+		return nil
+	}
+
+	return cher.New(ResponseTooLarge, cher.M{
+		"method":   method,
+		"path":     path,
+		"max_size": c.MaxResponseSize,
+	})
+}
+
+// DoStream executes a request against the configured server, returning a
+// Stream to decode each element of a JSON array response, or each record of a
+// newline delimited JSON response, as it is read rather than holding the
+// whole response in memory. The maximum response size of the client limits
+// the size of each element. The caller must close the Stream.
+func (c *Client) DoStream(ctx context.Context, method, path string, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*Stream, error) {
+	headers := http.Header{
+		"Accept": []string{"application/json, application/x-ndjson"},
+	}
+
+	res, err := c.DoRaw(ctx, method, path, headers, params, src, requestModifiers...)
+	if err != nil {
+		return nil, err
+	}
+
+	body := c.limitBody(res.Body)
+	buf := bufio.NewReader(body)
+
+	return &Stream{
+		c:      c,
+		method: method,
+		path:   path,
+		body:   body,
+		buf:    buf,
+		dec:    json.NewDecoder(buf),
+	}, nil
+}
+
+// Stream decodes the elements of a streamed response.
+type Stream struct {
+	c            *Client
+	method, path string
+
+	body *limitedBody
+	buf  *bufio.Reader
+	dec  *json.Decoder
+
+	started, array, done bool
+	err                  error
+}
+
+// Next decodes the next element of the stream into dst. It returns false when
+// the stream ends or an error occurs, which is returned by Err.
+func (s *Stream) Next(dst interface{}) bool {
+	if s.done {
+		return false
+	}
+
+	if !s.started {
+		s.started = true
+
+		array, err := isArray(s.buf)
+		if err == io.EOF {
+			return s.end(nil)
+		} else if err != nil {
+			return s.end(err)
+		}
+
+		if array {
+			// consume the opening bracket
+			if _, err := s.dec.Token(); err != nil {
+				return s.end(err)
+			}
+		}
+
+		s.array = array
+	}
+
+	if s.array && !s.dec.More() {
+		// consume the closing bracket, so a truncated array is an error
+		_, err := s.dec.Token()
+		return s.end(err)
+	}
+
+	if err := s.dec.Decode(dst); err == io.EOF && !s.array {
+		return s.end(nil)
+	} else if err != nil {
+		return s.end(err)
+	}
+
+	// limit the next element rather than the whole body, counting any input
+	// already read ahead towards it
+	s.body.base = s.dec.InputOffset()
+
+	return true
+}
+
+// end stops the stream, recording the error which stopped it, if any.
+func (s *Stream) end(err error) bool {
+	s.done = true
+
+	if err == nil {
+		return false
+	}
+
+	if tooLarge := s.c.tooLarge(err, s.method, s.path); tooLarge != nil {
+		s.err = tooLarge
+	} else {
+		s.err = &ClientTransportError{Method: s.method, Path: s.path, ErrorString: "could not unmarshal", cause: err}
+	}
+
+	return false
+}
+
+// Err returns the first error encountered by Next.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Close closes the underlying response body.
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
+
+// isArray reports whether the next value in buf is a JSON array, skipping any
+// leading whitespace.
+func isArray(buf *bufio.Reader) (bool, error) {
+	for {
+		b, err := buf.ReadByte()
+		if err != nil {
+			return false, err
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		return b == '[', buf.UnreadByte()
+	}
+}
@@ -0,0 +1,46 @@
+package jsonclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Get executes a GET request against the configured server, returning the
+// response decoded into a new T.
+func Get[T any](ctx context.Context, c *Client, path string, params url.Values, requestModifiers ...func(r *http.Request)) (*T, error) {
+	return doTyped[T](ctx, c, "GET", path, params, nil, requestModifiers...)
+}
+
+// Post executes a POST request with src as the body against the configured
+// server, returning the response decoded into a new T.
+func Post[T any](ctx context.Context, c *Client, path string, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*T, error) {
+	return doTyped[T](ctx, c, "POST", path, params, src, requestModifiers...)
+}
+
+// Put executes a PUT request with src as the body against the configured
+// server, returning the response decoded into a new T.
+func Put[T any](ctx context.Context, c *Client, path string, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*T, error) {
+	return doTyped[T](ctx, c, "PUT", path, params, src, requestModifiers...)
+}
+
+// Patch executes a PATCH request with src as the body against the configured
+// server, returning the response decoded into a new T.
+func Patch[T any](ctx context.Context, c *Client, path string, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*T, error) {
+	return doTyped[T](ctx, c, "PATCH", path, params, src, requestModifiers...)
+}
+
+// Delete executes a DELETE request against the configured server, returning
+// the response decoded into a new T.
+func Delete[T any](ctx context.Context, c *Client, path string, params url.Values, requestModifiers ...func(r *http.Request)) (*T, error) {
+	return doTyped[T](ctx, c, "DELETE", path, params, nil, requestModifiers...)
+}
+
+func doTyped[T any](ctx context.Context, c *Client, method, path string, params url.Values, src interface{}, requestModifiers ...func(r *http.Request)) (*T, error) {
+	var res T
+	if err := c.Do(ctx, method, path, params, src, &res, requestModifiers...); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,193 @@
+package jsonclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testVehicle struct {
+	VRM  string `json:"vrm"`
+	Make string `json:"make,omitempty"`
+}
+
+func TestTyped(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(cher.New(cher.NotFound, nil))
+			return
+		}
+
+		var req testVehicle
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		_ = json.NewEncoder(w).Encode(testVehicle{VRM: req.VRM, Make: r.Method})
+	}))
+	defer hs.Close()
+
+	client := NewClient(hs.URL+"/", nil)
+	ctx := context.Background()
+
+	res, err := Post[testVehicle](ctx, client, "vehicles", nil, &testVehicle{VRM: "CUV001"})
+	require.NoError(t, err)
+	assert.Equal(t, &testVehicle{VRM: "CUV001", Make: "POST"}, res)
+
+	res, err = Put[testVehicle](ctx, client, "vehicles", nil, &testVehicle{VRM: "CUV002"})
+	require.NoError(t, err)
+	assert.Equal(t, &testVehicle{VRM: "CUV002", Make: "PUT"}, res)
+
+	res, err = Patch[testVehicle](ctx, client, "vehicles", nil, &testVehicle{VRM: "CUV003"})
+	require.NoError(t, err)
+	assert.Equal(t, &testVehicle{VRM: "CUV003", Make: "PATCH"}, res)
+
+	res, err = Get[testVehicle](ctx, client, "vehicles", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "GET", res.Make)
+
+	res, err = Delete[testVehicle](ctx, client, "vehicles", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE", res.Make)
+
+	res, err = Get[testVehicle](ctx, client, "missing", nil)
+	assert.Nil(t, res)
+	assert.Equal(t, cher.New(cher.NotFound, nil), err)
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+
+		_, _ = w.Write([]byte(`{"vrm":"CUV001","make":"BMW"}`))
+	}))
+	defer hs.Close()
+
+	tests := []struct {
+		Name    string
+		Path    string
+		MaxSize int64
+		Error   string
+	}{
+		{"Unlimited", "vehicle", 0, ""},
+		{"Within", "vehicle", 64, ""},
+		{"Exceeded", "vehicle", 16, ResponseTooLarge},
+		{"ErrorExceeded", "error", 16, ResponseTooLarge},
+		{"ErrorWithin", "error", 64, "bad_gateway"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			client := NewClient(hs.URL+"/", nil)
+			client.MaxResponseSize = test.MaxSize
+
+			res, err := Get[testVehicle](context.Background(), client, test.Path, nil)
+			if test.Error == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "BMW", res.Make)
+				return
+			}
+
+			var cErr cher.E
+			if assert.ErrorAs(t, err, &cErr) {
+				assert.Equal(t, test.Error, cErr.Code)
+			}
+		})
+	}
+}
+
+func TestDoStream(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Body    string
+		MaxSize int64
+
+		VRMs  []string
+		Error string
+	}{
+		{"Array", ` [{"vrm":"A"},{"vrm":"B"},{"vrm":"C"}]`, 0, []string{"A", "B", "C"}, ""},
+		{"EmptyArray", `[]`, 0, nil, ""},
+		{"NDJSON", "{\"vrm\":\"A\"}\n{\"vrm\":\"B\"}\n", 0, []string{"A", "B"}, ""},
+		{"Empty", ``, 0, nil, ""},
+		{"TruncatedArray", `[{"vrm":"A"},{"vrm":"B"}`, 0, []string{"A", "B"}, "could not unmarshal"},
+		{"Invalid", `{"vrm":"A"} nope`, 0, []string{"A"}, "could not unmarshal"},
+		{"ElementsWithinLimit", `[{"vrm":"A"},{"vrm":"B"},{"vrm":"C"},{"vrm":"D"},{"vrm":"E"}]`, 24, []string{"A", "B", "C", "D", "E"}, ""},
+		{"ElementTooLarge", `[{"vrm":"A"},{"vrm":"BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB"}]`, 24, []string{"A"}, ResponseTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(test.Body))
+			}))
+			defer hs.Close()
+
+			client := NewClient(hs.URL+"/", nil)
+			client.MaxResponseSize = test.MaxSize
+
+			stream, err := client.DoStream(context.Background(), "GET", "vehicles", nil, nil)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			var vrms []string
+			var v testVehicle
+
+			for stream.Next(&v) {
+				vrms = append(vrms, v.VRM)
+			}
+
+			// the stream stays ended after an error
+			assert.False(t, stream.Next(&v))
+
+			assert.Equal(t, test.VRMs, vrms)
+
+			err = stream.Err()
+
+			if test.Error == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.Error)
+			}
+		})
+	}
+
+	t.Run("CloseEarly", func(t *testing.T) {
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"vrm":"A"},{"vrm":"B"}]`))
+		}))
+		defer hs.Close()
+
+		client := NewClient(hs.URL+"/", nil)
+
+		stream, err := client.DoStream(context.Background(), "GET", "vehicles", nil, nil)
+		require.NoError(t, err)
+
+		var v testVehicle
+		require.True(t, stream.Next(&v))
+		assert.Equal(t, "A", v.VRM)
+
+		assert.NoError(t, stream.Close())
+		assert.NoError(t, stream.Err())
+	})
+
+	t.Run("ErrorResponse", func(t *testing.T) {
+		hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(cher.New(cher.NotFound, nil))
+		}))
+		defer hs.Close()
+
+		client := NewClient(hs.URL+"/", nil)
+
+		stream, err := client.DoStream(context.Background(), "GET", "vehicles", nil, nil)
+		assert.Nil(t, stream)
+		assert.Equal(t, cher.New(cher.NotFound, nil), err)
+	})
+}
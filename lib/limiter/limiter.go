@@ -1,5 +1,9 @@
 package limiter
 
+import (
+	"time"
+)
+
 // Limiter is an interface implemented by all rate limiting schemes.
 type Limiter interface {
 	// Allow reports whether an event with the given token can happen
@@ -30,3 +34,20 @@ func (t Tiered) Allow(token string) (count int64, ok bool) {
 	ok = true
 	return
 }
+
+// Policy is implemented by Limiters which can describe the rate they
+// enforce, allowing it to be advertised to callers.
+type Policy interface {
+	// Policy returns the maximum number of events allowed within window.
+	Policy() (limit int64, window time.Duration)
+}
+
+// Resetter is implemented by Limiters which can report when the window of a
+// token resets as they allow an event, allowing callers to be told when to
+// retry.
+type Resetter interface {
+	// AllowReset reports whether an event with the given token can happen,
+	// as Allow does, along with the time until the oldest event of token
+	// within the window expires, freeing capacity for another event.
+	AllowReset(token string) (count int64, ok bool, reset time.Duration)
+}
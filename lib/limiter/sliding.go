@@ -34,6 +34,14 @@ type Sliding struct {
 //
 // If redis is unavailable, Allow will allow all tokens temporarily.
 func (s *Sliding) Allow(token string) (count int64, ok bool) {
+	count, ok, _ = s.AllowReset(token)
+	return
+}
+
+// AllowReset reports whether an event with the given token can happen, as
+// Allow does, along with the time until the oldest event of token within the
+// window expires. If redis is unavailable, the reset is the window duration.
+func (s *Sliding) AllowReset(token string) (count int64, ok bool, reset time.Duration) {
 	if s.RedisPrefix != "" {
 		token = s.RedisPrefix + "/" + token
 	}
@@ -46,17 +54,21 @@ func (s *Sliding) Allow(token string) (count int64, ok bool) {
 	// trim elements older than window from sorted set
 	// get cardinality of elements still in set
 	// add current second as element in set (if not already added)
+	// get the oldest element still in set
 	// reset expiry of sorted set to window
 	pipe.ZRemRangeByScore(token, "0", strconv.FormatInt(prior.Unix(), 10))
 	pipe.ZCard(token)
 	pipe.ZAddNX(token, redis.Z{Score: float64(now.Unix()), Member: now.Unix()})
+	pipe.ZRangeWithScores(token, 0, 0)
 	pipe.Expire(token, s.WindowDuration)
 
 	// TODO(jc): replace the above redis calls with lua, can then invoke SHA1
 	// hash of script instead of sending command each time.
 
+	reset = s.WindowDuration
+
 	result, err := pipe.Exec()
-	if err != nil || len(result) != 4 {
+	if err != nil || len(result) != 5 {
 		// allow all events when cannot connect to redis
 		count = s.WindowMaximum
 		ok = true
@@ -70,5 +82,24 @@ func (s *Sliding) Allow(token string) (count int64, ok bool) {
 		}
 	}
 
+	if oldest, isOK := result[3].(*redis.ZSliceCmd); isOK && len(oldest.Val()) > 0 {
+		reset = slidingReset(time.Unix(int64(oldest.Val()[0].Score), 0), s.WindowDuration, now)
+	}
+
 	return
 }
+
+// Policy returns the maximum number of events allowed within the window.
+func (s *Sliding) Policy() (limit int64, window time.Duration) {
+	return s.WindowMaximum, s.WindowDuration
+}
+
+// slidingReset returns the time until an event at start leaves the window.
+func slidingReset(start time.Time, window time.Duration, now time.Time) time.Duration {
+	reset := start.Add(window).Sub(now)
+	if reset < 0 {
+		return 0
+	}
+
+	return reset
+}
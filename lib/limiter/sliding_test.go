@@ -0,0 +1,43 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlidingPolicy(t *testing.T) {
+	var l Limiter = &Sliding{WindowDuration: time.Minute, WindowMaximum: 10}
+
+	p, ok := l.(Policy)
+	if assert.True(t, ok) {
+		limit, window := p.Policy()
+
+		assert.Equal(t, int64(10), limit)
+		assert.Equal(t, time.Minute, window)
+	}
+
+	_, ok = l.(Resetter)
+	assert.True(t, ok)
+}
+
+func TestSlidingReset(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		Name  string
+		Start time.Time
+		Reset time.Duration
+	}{
+		{"WindowStart", now, time.Minute},
+		{"WithinWindow", now.Add(-45 * time.Second), 15 * time.Second},
+		{"Expired", now.Add(-2 * time.Minute), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Equal(t, test.Reset, slidingReset(test.Start, time.Minute, now))
+		})
+	}
+}
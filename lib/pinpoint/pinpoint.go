@@ -41,12 +41,12 @@ type Client struct {
 	*jsonclient.Client
 }
 
-func New(baseURL string) *Client {
+func New(baseURL string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Timeout: 5 * time.Second,
 	}
 
-	return &Client{jsonclient.NewClient(baseURL, httpClient)}
+	return &Client{jsonclient.NewClient(baseURL, httpClient, opts...)}
 }
 
 func (c *Client) GetJobs(ctx context.Context) ([]Job, error) {
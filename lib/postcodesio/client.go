@@ -68,18 +68,22 @@ type Client struct {
 
 // FailoverClient contains many clients and will attempt to execute and
 // client operations on them in order until the first non-error response
-// is encountered.
+// is encountered. Clients whose circuit breaker is open are skipped, so
+// clients given to a FailoverClient should be created with
+// jsonclient.WithBreaker.
 type FailoverClient struct {
 	clients []*Client
 }
 
-// New generates the client struct with populated net/http client
-func New(baseURL string) *Client {
+// New generates the client struct with populated net/http client. Options,
+// such as jsonclient.WithInstrumentation or jsonclient.WithBreaker, configure
+// the JSON client.
+func New(baseURL string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Timeout: 5 * time.Second,
 	}
 
-	jcc := jsonclient.NewClient(baseURL, httpClient)
+	jcc := jsonclient.NewClient(baseURL, httpClient, opts...)
 
 	jcc.UserAgent = DefaultUserAgent
 
@@ -141,6 +145,12 @@ func (fc *FailoverClient) ReverseGeocode(ctx context.Context, latitude, longitud
 	var errors []cher.E
 
 	for _, cli := range fc.clients {
+		// skip hosts which are failing rather than wait for them to fail
+		if cli.BreakerState() == jsonclient.BreakerOpen {
+			errors = append(errors, cher.New(cher.CircuitOpen, cher.M{"host": cli.Host}))
+			continue
+		}
+
 		pc, err := cli.ReverseGeocode(ctx, latitude, longitude)
 		if err == nil {
 			return pc, nil
@@ -163,6 +173,12 @@ func (fc *FailoverClient) Geocode(ctx context.Context, postcode string) (*Postco
 	var errors []cher.E
 
 	for _, cli := range fc.clients {
+		// skip hosts which are failing rather than wait for them to fail
+		if cli.BreakerState() == jsonclient.BreakerOpen {
+			errors = append(errors, cher.New(cher.CircuitOpen, cher.M{"host": cli.Host}))
+			continue
+		}
+
 		pc, err := cli.Geocode(ctx, postcode)
 		if err == nil {
 			return pc, nil
@@ -2,8 +2,11 @@ package postcodesio_test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient"
 	"github.com/cuvva/cuvva-public-go/lib/postcodesio"
 	"github.com/stretchr/testify/require"
 )
@@ -52,3 +55,49 @@ func TestFailoverClient_Geocode(t *testing.T) {
 	require.Equal(t, 51.539746, pc.Latitude)
 	require.Equal(t, -0.103053, pc.Longitude)
 }
+
+// TestFailoverSkipsOpenCircuit tests that a client whose circuit is open is
+// skipped without being called
+func TestFailoverSkipsOpenCircuit(t *testing.T) {
+	var deadRequests int
+
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadRequests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer dead.Close()
+
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":200,"result":{"postcode":"N1 1AA","parish":"Islington"}}`))
+	}))
+	defer live.Close()
+
+	breaker := jsonclient.NewCircuitBreaker(jsonclient.BreakerConfig{MinRequests: 1})
+
+	std := postcodesio.New(dead.URL, jsonclient.WithBreaker(breaker))
+	fallback := postcodesio.New(live.URL, jsonclient.WithBreaker(breaker))
+
+	fallbackClient, err := postcodesio.NewFailoverClient(std, fallback)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		pc, err := fallbackClient.Geocode(context.Background(), "N1 1AA")
+		require.NoError(t, err)
+		require.Equal(t, "Islington", pc.Area)
+	}
+
+	require.Equal(t, 1, deadRequests)
+	require.Equal(t, jsonclient.BreakerOpen, std.BreakerState())
+}
+
+// TestBreakerOptIn tests that clients only have a circuit breaker when one is
+// given
+func TestBreakerOptIn(t *testing.T) {
+	a := postcodesio.New(postcodesio.DefaultBaseURL)
+	require.Nil(t, a.Breaker)
+
+	breaker := jsonclient.NewCircuitBreaker(jsonclient.BreakerConfig{})
+
+	b := postcodesio.New(postcodesio.DefaultBaseURL, jsonclient.WithBreaker(breaker))
+	require.Same(t, breaker, b.Breaker)
+}
@@ -34,13 +34,9 @@ func ErrorHandler(ctx context.Context, w http.ResponseWriter, err error) {
 	// add it to the reqest log instance
 	clog.SetError(ctx, err)
 
-	var body cher.E
-
-	switch err := err.(type) {
-	case cher.E:
-		body = err
-
-	default:
+	// resolve through wrapped errors and any registered translators
+	body, ok := cher.Translate(err)
+	if !ok {
 		body = cher.E{Code: cher.Unknown}
 	}
 
@@ -1,10 +1,14 @@
 package restbase
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/cuvva/cuvva-public-go/lib/cher"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,4 +43,28 @@ func TestREST(t *testing.T) {
 			})
 		}
 	})
+	t.Run("ErrorHandler", func(t *testing.T) {
+		tests := []struct {
+			Name  string
+			Error error
+
+			Bytes      []byte
+			StatusCode int
+		}{
+			{"Cher", cher.New(cher.NotFound, nil), []byte(`{"code":"not_found"}` + "\n"), http.StatusNotFound},
+			{"WrappedCher", fmt.Errorf("foo: %w", cher.New(cher.NotFound, nil)), []byte(`{"code":"not_found"}` + "\n"), http.StatusNotFound},
+			{"Unknown", errors.New("foo"), []byte(`{"code":"unknown"}` + "\n"), http.StatusInternalServerError},
+		}
+
+		for _, test := range tests {
+			t.Run(test.Name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+
+				ErrorHandler(context.Background(), w, test.Error)
+
+				assert.Equal(t, test.StatusCode, w.Code)
+				assert.Equal(t, test.Bytes, w.Body.Bytes())
+			})
+		}
+	})
 }
@@ -14,14 +14,15 @@ type Client struct {
 	apiKey string
 }
 
-// NewClient creates a new sanctions.io API client.
-func NewClient(baseURL, apiKey string) *Client {
+// NewClient creates a new sanctions.io API client, configured by any options,
+// such as jsonclient.WithInstrumentation.
+func NewClient(baseURL, apiKey string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Timeout: 5 * time.Second,
 	}
 
 	return &Client{
-		jsonclient.NewClient(baseURL, httpClient),
+		jsonclient.NewClient(baseURL, httpClient, opts...),
 		apiKey,
 	}
 }
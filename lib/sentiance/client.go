@@ -20,14 +20,14 @@ type HTTPClient struct {
 	*jsonclient.Client
 }
 
-func NewClient(token string) *HTTPClient {
+func NewClient(token string, opts ...jsonclient.Option) *HTTPClient {
 	httpClient := &http.Client{
 		Transport: jsonclient.NewAuthenticatedRoundTripper(nil, "Bearer", token),
 		Timeout:   5 * time.Second,
 	}
 
 	return &HTTPClient{
-		jsonclient.NewClient("https://api.sentiance.com/", httpClient),
+		jsonclient.NewClient("https://api.sentiance.com/", httpClient, opts...),
 	}
 }
 
@@ -18,13 +18,13 @@ type Client struct {
 	key    string
 }
 
-func NewClient(baseURL, key string) *Client {
+func NewClient(baseURL, key string, opts ...jsonclient.Option) *Client {
 	httpClient := &http.Client{
 		Timeout: 5 * time.Second,
 	}
 
 	return &Client{
-		jsonclient.NewClient(baseURL, httpClient),
+		jsonclient.NewClient(baseURL, httpClient, opts...),
 		key,
 	}
 }
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+	"github.com/cuvva/cuvva-public-go/tools/crpcdiff"
+	"github.com/spf13/cobra"
+)
+
+// DiffCmd is the cobra definition for the crpcdiff command
+var DiffCmd = &cobra.Command{
+	Use:   "crpcdiff <catalogue> <from> [to]",
+	Short: "list the methods changed between two crpc versions",
+	Long: "Lists the methods added, removed or overridden between two versions of a crpc catalogue, " +
+		"and fails if any change is backwards-incompatible. The catalogue is read from a file, a URL " +
+		"such as https://service/_catalogue, or stdin when given as -. The to version defaults to latest.",
+
+	Args:          cobra.RangeArgs(2, 3),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return err
+		}
+
+		allowBreaking, err := cmd.Flags().GetBool("allow-breaking")
+		if err != nil {
+			return err
+		}
+
+		catalogue, err := crpcdiff.ReadCatalogue(args[0])
+		if err != nil {
+			return err
+		}
+
+		to := crpc.VersionLatest
+		if len(args) == 3 {
+			to = args[2]
+		}
+
+		diff, err := catalogue.Diff(args[1], to)
+		if err != nil {
+			return err
+		}
+
+		if outputJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "\t")
+
+			if err := enc.Encode(diff); err != nil {
+				return err
+			}
+		} else {
+			crpcdiff.PrintDiff(cmd.OutOrStdout(), diff)
+		}
+
+		if diff.Breaking() && !allowBreaking {
+			return fmt.Errorf("backwards-incompatible changes between %s and %s", diff.From, diff.To)
+		}
+
+		return nil
+	},
+}
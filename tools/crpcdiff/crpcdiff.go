@@ -0,0 +1,91 @@
+package crpcdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+)
+
+// ReadCatalogue reads a crpc catalogue from a file, a URL such as
+// https://service/_catalogue, or stdin when source is -.
+func ReadCatalogue(source string) (*crpc.Catalogue, error) {
+	var r io.ReadCloser
+
+	switch {
+	case source == "-":
+		r = os.Stdin
+
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		res, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("fetching catalogue: unexpected status %s", res.Status)
+		}
+
+		r = res.Body
+
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+
+		r = f
+	}
+
+	defer r.Close()
+
+	var catalogue crpc.Catalogue
+	if err := json.NewDecoder(r).Decode(&catalogue); err != nil {
+		return nil, fmt.Errorf("decoding catalogue: %w", err)
+	}
+
+	return &catalogue, nil
+}
+
+// PrintDiff writes a human readable summary of diff to w.
+func PrintDiff(w io.Writer, diff *crpc.VersionDiff) {
+	fmt.Fprintf(w, "Changes from %s to %s\n", diff.From, diff.To)
+
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 {
+		fmt.Fprintln(w, "\nNo methods changed")
+		return
+	}
+
+	if len(diff.Added) > 0 {
+		fmt.Fprintln(w, "\nAdded:")
+
+		for _, mi := range diff.Added {
+			fmt.Fprintf(w, "  + %s (%s)\n", mi.Method, mi.Version)
+		}
+	}
+
+	if len(diff.Removed) > 0 {
+		fmt.Fprintln(w, "\nRemoved (breaking):")
+
+		for _, mi := range diff.Removed {
+			fmt.Fprintf(w, "  - %s (%s)\n", mi.Method, mi.Version)
+		}
+	}
+
+	if len(diff.Changed) > 0 {
+		fmt.Fprintln(w, "\nOverridden:")
+
+		for _, mc := range diff.Changed {
+			fmt.Fprintf(w, "  ~ %s (%s -> %s)\n", mc.Method, mc.From.Version, mc.To.Version)
+
+			for _, i := range mc.Incompatibilities {
+				fmt.Fprintf(w, "      breaking: %s\n", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package crpcdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCatalogue(t *testing.T) {
+	ping := crpc.MethodInfo{Method: "ping", Version: "2019-01-01"}
+	pong := crpc.MethodInfo{Method: "pong", Version: "2019-02-01"}
+
+	b, err := json.Marshal(&crpc.Catalogue{
+		Latest: "2019-02-01",
+		Versions: map[string][]crpc.MethodInfo{
+			"2019-01-01": {ping},
+			"2019-02-01": {ping, pong},
+		},
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "catalogue.json")
+	require.NoError(t, os.WriteFile(path, b, 0o644))
+
+	catalogue, err := ReadCatalogue(path)
+	require.NoError(t, err)
+
+	diff, err := catalogue.Diff("2019-01-01", "2019-02-01")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	PrintDiff(&buf, diff)
+
+	assert.Equal(t, "Changes from 2019-01-01 to 2019-02-01\n\nAdded:\n  + pong (2019-02-01)\n", buf.String())
+}
+
+func TestReadCatalogueInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalogue.json")
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o644))
+
+	_, err := ReadCatalogue(path)
+	assert.Error(t, err)
+}
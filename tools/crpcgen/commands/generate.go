@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cuvva/cuvva-public-go/tools/crpcgen"
+	"github.com/spf13/cobra"
+)
+
+// GenerateCmd is the cobra definition for the "generate" command
+var GenerateCmd = &cobra.Command{
+	Use:   "generate <import path>",
+	Short: "Generate a typed client, fake and registration func for a service interface",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		iface, err := cmd.Flags().GetString("interface")
+		if err != nil {
+			return err
+		}
+
+		version, err := cmd.Flags().GetString("version")
+		if err != nil {
+			return err
+		}
+
+		pkgName, err := cmd.Flags().GetString("package")
+		if err != nil {
+			return err
+		}
+
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		svc, err := crpcgen.Load(args[0], iface, version)
+		if err != nil {
+			return err
+		}
+
+		src, err := crpcgen.Generate(svc, pkgName)
+		if err != nil {
+			return err
+		}
+
+		if output == "" {
+			_, err = fmt.Print(string(src))
+			return err
+		}
+
+		return os.WriteFile(output, src, 0o644)
+	},
+}
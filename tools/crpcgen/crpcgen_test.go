@@ -0,0 +1,71 @@
+package crpcgen
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDir(t *testing.T) {
+	svc, err := LoadDir("testdata/service", "example.com/service", "Service", "2023-01-10")
+	require.NoError(t, err)
+
+	assert.Equal(t, "service", svc.PackageName)
+	assert.Equal(t, map[string]string{"time": "time"}, svc.Imports)
+
+	require.Len(t, svc.Methods, 3)
+
+	assert.Equal(t, &Method{
+		Name:         "GetPolicy",
+		RPCName:      "get_policy",
+		Version:      "2023-01-10",
+		RequestType:  "*service.GetPolicyRequest",
+		ResponseType: "*service.Policy",
+		Schema:       "service.GetPolicyRequestSchema",
+	}, svc.Methods[0])
+
+	assert.Equal(t, &Method{
+		Name:         "ListPolicies",
+		RPCName:      "list_all_policies",
+		Version:      "2024-05-02",
+		ResponseType: "[]*service.Policy",
+	}, svc.Methods[1])
+
+	assert.Equal(t, "map[string]time.Time", svc.Methods[2].ResponseType)
+}
+
+func TestLoadDirErrors(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Interface string
+		Version   string
+		Error     string
+	}{
+		{"NotFound", "Missing", "2023-01-10", "interface Missing not found in example.com/service"},
+		{"NoVersion", "Service", "", "Service.GetPolicy: no version, add a crpc:version directive or set a default"},
+		{"NoSchema", "MissingSchema", "2023-01-10", "MissingSchema.Update: no schema variable PolicySchema declared for Policy"},
+		{"BadSignature", "BadSignature", "2023-01-10", "BadSignature.Update: first argument must be context.Context, got *Policy"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := LoadDir("testdata/service", "example.com/service", test.Interface, test.Version)
+			assert.EqualError(t, err, test.Error)
+		})
+	}
+}
+
+func TestGenerateExampleIsUpToDate(t *testing.T) {
+	svc, err := Load("github.com/cuvva/cuvva-public-go/lib/crpc/example", "Service", "2017-11-08")
+	require.NoError(t, err)
+
+	src, err := Generate(svc, "exampleclient")
+	require.NoError(t, err)
+
+	expected, err := os.ReadFile("../../lib/crpc/example/exampleclient/client_gen.go")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(expected), string(src), "run go generate ./lib/crpc/example")
+}
@@ -0,0 +1,116 @@
+package crpcgen
+
+import (
+	"bytes"
+	"go/format"
+	"sort"
+	"text/template"
+)
+
+type importSpec struct {
+	Name, Path string
+}
+
+type templateData struct {
+	*Service
+
+	Package      string
+	ExtraImports []importSpec
+}
+
+// Generate returns the formatted source of a Go file in package pkgName
+// containing a typed client, a fake and a server registration function for
+// the service.
+func Generate(svc *Service, pkgName string) ([]byte, error) {
+	data := templateData{
+		Service: svc,
+		Package: pkgName,
+	}
+
+	for name, importPath := range svc.Imports {
+		data.ExtraImports = append(data.ExtraImports, importSpec{name, importPath})
+	}
+
+	sort.Slice(data.ExtraImports, func(i, j int) bool {
+		return data.ExtraImports[i].Path < data.ExtraImports[j].Path
+	})
+
+	var buf bytes.Buffer
+
+	if err := clientTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by crpcgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cuvva/cuvva-public-go/lib/cher"
+	"github.com/cuvva/cuvva-public-go/lib/crpc"
+	"github.com/cuvva/cuvva-public-go/lib/jsonclient"
+	{{ .PackageName }} "{{ .ImportPath }}"
+	{{- range .ExtraImports }}
+	{{ .Name }} "{{ .Path }}"
+	{{- end }}
+)
+
+// Client is a typed crpc client for {{ .PackageName }}.{{ .Interface }}.
+type Client struct {
+	*crpc.Client
+}
+
+var _ {{ .PackageName }}.{{ .Interface }} = (*Client)(nil)
+
+// NewClient returns a client configured with a transport scheme, remote host
+// and URL prefix supplied as a URL <scheme>://<host></prefix>. Options, such
+// as jsonclient.WithInstrumentation, are passed on to crpc.NewClient.
+func NewClient(ctx context.Context, baseURL string, c *http.Client, opts ...jsonclient.Option) *Client {
+	return &Client{crpc.NewClient(ctx, baseURL, c, opts...)}
+}
+{{ range .Methods }}
+// {{ .Name }} calls {{ .RPCName }} (version {{ .Version }}).
+func (c *Client) {{ .Name }}(ctx context.Context{{ if .RequestType }}, req {{ .RequestType }}{{ end }}) {{ if .ResponseType }}({{ .ResponseType }}, error){{ else }}error{{ end }} {
+	{{- if .ResponseType }}
+	var res {{ .ResponseType }}
+	err := c.Do(ctx, "{{ .RPCName }}", "{{ .Version }}", {{ if .RequestType }}req{{ else }}nil{{ end }}, &res)
+	return res, err
+	{{- else }}
+	return c.Do(ctx, "{{ .RPCName }}", "{{ .Version }}", {{ if .RequestType }}req{{ else }}nil{{ end }}, nil)
+	{{- end }}
+}
+{{ end }}
+// Fake is an implementation of {{ .PackageName }}.{{ .Interface }} for use in
+// tests. Each method calls the matching func field, or returns a
+// not_implemented error if it is nil.
+type Fake struct {
+	{{- range .Methods }}
+	{{ .Name }}Func func(ctx context.Context{{ if .RequestType }}, req {{ .RequestType }}{{ end }}) {{ if .ResponseType }}({{ .ResponseType }}, error){{ else }}error{{ end }}
+	{{- end }}
+}
+
+var _ {{ .PackageName }}.{{ .Interface }} = (*Fake)(nil)
+{{ range .Methods }}
+// {{ .Name }} calls {{ .Name }}Func.
+func (f *Fake) {{ .Name }}(ctx context.Context{{ if .RequestType }}, req {{ .RequestType }}{{ end }}) {{ if .ResponseType }}({{ .ResponseType }}, error){{ else }}error{{ end }} {
+	if f.{{ .Name }}Func == nil {
+		return {{ if .ResponseType }}nil, {{ end }}cher.New("not_implemented", cher.M{"method": "{{ .RPCName }}", "version": "{{ .Version }}"})
+	}
+
+	return f.{{ .Name }}Func(ctx{{ if .RequestType }}, req{{ end }})
+}
+{{ end }}
+// Register associates each method of svc with s, using the same methods and
+// versions the Client is pinned to.
+func Register(s *crpc.Server, svc {{ .PackageName }}.{{ .Interface }}, mw ...crpc.MiddlewareFunc) {
+	{{- range .Methods }}
+	s.Register("{{ .RPCName }}", "{{ .Version }}", {{ if .Schema }}{{ .Schema }}{{ else }}nil{{ end }}, svc.{{ .Name }}, mw...)
+	{{- end }}
+}
+`))
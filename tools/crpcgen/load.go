@@ -0,0 +1,377 @@
+package crpcgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cuvva/cuvva-public-go/lib/snek"
+)
+
+// Service describes a crpc service interface and the methods it exposes.
+type Service struct {
+	// ImportPath and PackageName identify the package declaring the interface.
+	ImportPath  string
+	PackageName string
+
+	// Interface is the name of the interface type, e.g. "Service".
+	Interface string
+
+	Methods []*Method
+
+	// Imports are any additional packages referenced by method signatures,
+	// mapped from their local name to their import path.
+	Imports map[string]string
+}
+
+// Method describes a single RPC method of a service interface.
+type Method struct {
+	// Name is the Go method name, e.g. "GetPolicy".
+	Name string
+
+	// RPCName and Version are the crpc method name and version the
+	// method is pinned to, e.g. "get_policy" and "2024-05-02".
+	RPCName string
+	Version string
+
+	// RequestType and ResponseType are the qualified Go types of the request
+	// and response, or empty if the method has no request or response.
+	RequestType  string
+	ResponseType string
+
+	// Schema is the qualified name of the variable holding the request JSON
+	// Schema, or empty if the method has no request.
+	Schema string
+}
+
+// Directives which may be used in the comments of interface methods to
+// override the crpc method name or version.
+const (
+	directiveMethod  = "crpc:method"
+	directiveVersion = "crpc:version"
+)
+
+// Load parses the package at importPath and returns the interface named
+// iface. Methods without a crpc:version directive are pinned to
+// defaultVersion.
+func Load(importPath, iface, defaultVersion string) (*Service, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", importPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %w", importPath, err)
+	}
+
+	return LoadDir(strings.TrimSpace(string(out)), importPath, iface, defaultVersion)
+}
+
+// LoadDir is the same as Load, but parses the package in dir.
+func LoadDir(dir, importPath, iface, defaultVersion string) (*Service, error) {
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	l := &loader{
+		fset:    fset,
+		pkg:     pkg,
+		vars:    make(map[string]bool),
+		imports: make(map[string]string),
+	}
+
+	var spec *ast.InterfaceType
+	var specFile *ast.File
+
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+
+			for _, s := range gd.Specs {
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					if it, ok := s.Type.(*ast.InterfaceType); ok && s.Name.Name == iface {
+						spec, specFile = it, file
+					}
+
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						l.vars[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	if spec == nil {
+		return nil, fmt.Errorf("interface %s not found in %s", iface, importPath)
+	}
+
+	l.file = specFile
+
+	svc := &Service{
+		ImportPath:  importPath,
+		PackageName: pkg.Name,
+		Interface:   iface,
+		Imports:     l.imports,
+	}
+
+	for _, field := range spec.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", fset.Position(field.Pos()))
+		}
+
+		m, err := l.method(field.Names[0].Name, ft, defaultVersion)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", iface, field.Names[0].Name, err)
+		}
+
+		if err := applyDirectives(m, field.Doc, field.Comment); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", iface, m.Name, err)
+		}
+
+		if m.Version == "" {
+			return nil, fmt.Errorf("%s.%s: no version, add a %s directive or set a default", iface, m.Name, directiveVersion)
+		}
+
+		svc.Methods = append(svc.Methods, m)
+	}
+
+	sort.Slice(svc.Methods, func(i, j int) bool {
+		return svc.Methods[i].Name < svc.Methods[j].Name
+	})
+
+	return svc, nil
+}
+
+type loader struct {
+	fset *token.FileSet
+	pkg  *ast.Package
+	file *ast.File
+
+	vars    map[string]bool
+	imports map[string]string
+}
+
+func (l *loader) method(name string, ft *ast.FuncType, defaultVersion string) (*Method, error) {
+	m := &Method{
+		Name:    name,
+		RPCName: snek.Snek(name),
+		Version: defaultVersion,
+	}
+
+	params := flatten(ft.Params)
+	results := flatten(ft.Results)
+
+	if len(params) < 1 || len(params) > 2 {
+		return nil, fmt.Errorf("input must be (context.Context) or (context.Context, *T), got %d arguments", len(params))
+	} else if len(results) < 1 || len(results) > 2 {
+		return nil, fmt.Errorf("output must be (error) or (*T, error), got %d arguments", len(results))
+	}
+
+	if l.render(params[0]) != "context.Context" {
+		return nil, fmt.Errorf("first argument must be context.Context, got %s", l.render(params[0]))
+	} else if l.render(results[len(results)-1]) != "error" {
+		return nil, fmt.Errorf("last result must be error, got %s", l.render(results[len(results)-1]))
+	}
+
+	if len(params) == 2 {
+		star, ok := params[1].(*ast.StarExpr)
+		if !ok {
+			return nil, fmt.Errorf("last argument must be a pointer, got %s", l.render(params[1]))
+		}
+
+		ident, ok := star.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("last argument must be a struct declared in %s, got %s", l.pkg.Name, l.render(params[1]))
+		}
+
+		schema := ident.Name + "Schema"
+		if !l.vars[schema] {
+			return nil, fmt.Errorf("no schema variable %s declared for %s", schema, ident.Name)
+		}
+
+		typ, err := l.qualify(params[1])
+		if err != nil {
+			return nil, err
+		}
+
+		m.RequestType = l.render(typ)
+		m.Schema = l.pkg.Name + "." + schema
+	}
+
+	if len(results) == 2 {
+		typ, err := l.qualify(results[0])
+		if err != nil {
+			return nil, err
+		}
+
+		m.ResponseType = l.render(typ)
+	}
+
+	return m, nil
+}
+
+// flatten expands a field list so each type appears once per parameter.
+func flatten(fl *ast.FieldList) []ast.Expr {
+	if fl == nil {
+		return nil
+	}
+
+	var exprs []ast.Expr
+
+	for _, field := range fl.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			exprs = append(exprs, field.Type)
+		}
+	}
+
+	return exprs
+}
+
+// qualify returns a copy of expr with identifiers declared in the service
+// package qualified by its package name, recording any imports used.
+func (l *loader) qualify(expr ast.Expr) (ast.Expr, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if types.Universe.Lookup(e.Name) != nil {
+			return ast.NewIdent(e.Name), nil
+		} else if !ast.IsExported(e.Name) {
+			return nil, fmt.Errorf("type %s is not exported", e.Name)
+		}
+
+		return &ast.SelectorExpr{X: ast.NewIdent(l.pkg.Name), Sel: ast.NewIdent(e.Name)}, nil
+
+	case *ast.SelectorExpr:
+		pkg, ok := e.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %s", l.render(e))
+		}
+
+		importPath, err := l.importPath(pkg.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		l.imports[pkg.Name] = importPath
+
+		return &ast.SelectorExpr{X: ast.NewIdent(pkg.Name), Sel: ast.NewIdent(e.Sel.Name)}, nil
+
+	case *ast.StarExpr:
+		x, err := l.qualify(e.X)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.StarExpr{X: x}, nil
+
+	case *ast.ArrayType:
+		elt, err := l.qualify(e.Elt)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.ArrayType{Len: e.Len, Elt: elt}, nil
+
+	case *ast.MapType:
+		key, err := l.qualify(e.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := l.qualify(e.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.MapType{Key: key, Value: value}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", l.render(expr))
+}
+
+func (l *loader) importPath(name string) (string, error) {
+	for _, imp := range l.file.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return "", err
+		}
+
+		if imp.Name != nil && imp.Name.Name == name {
+			return importPath, nil
+		} else if imp.Name == nil && path.Base(importPath) == name {
+			return importPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot resolve import of package %s", name)
+}
+
+func (l *loader) render(expr ast.Expr) string {
+	var buf bytes.Buffer
+
+	printer.Fprint(&buf, token.NewFileSet(), expr)
+
+	return buf.String()
+}
+
+func applyDirectives(m *Method, groups ...*ast.CommentGroup) error {
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+
+		for _, c := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+
+			fields := strings.Fields(text)
+			if len(fields) == 0 {
+				continue
+			}
+
+			switch fields[0] {
+			case directiveMethod, directiveVersion:
+				if len(fields) != 2 {
+					return fmt.Errorf("%s directive expects one argument", fields[0])
+				}
+
+				if fields[0] == directiveMethod {
+					m.RPCName = fields[1]
+				} else {
+					m.Version = fields[1]
+				}
+			}
+		}
+	}
+
+	return nil
+}
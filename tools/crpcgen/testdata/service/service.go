@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+type Service interface {
+	GetPolicy(ctx context.Context, req *GetPolicyRequest) (*Policy, error)
+
+	//crpc:method list_all_policies
+	//crpc:version 2024-05-02
+	ListPolicies(context.Context) ([]*Policy, error)
+
+	ListStartDates(context.Context) (map[string]time.Time, error)
+}
+
+type GetPolicyRequest struct {
+	ID string `json:"id"`
+}
+
+var GetPolicyRequestSchema = gojsonschema.NewStringLoader(`{"type": "object"}`)
+
+type Policy struct {
+	ID        string    `json:"id"`
+	StartDate time.Time `json:"start_date"`
+}
+
+type MissingSchema interface {
+	Update(context.Context, *Policy) error
+}
+
+type BadSignature interface {
+	Update(*Policy) error
+}